@@ -17,8 +17,10 @@
 package main
 
 import (
+	"bytes"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
@@ -29,7 +31,15 @@ import (
 	"github.com/SUSE/container-suseconnect/internal/regionsrv"
 )
 
-var logCredentialsErrors = false
+var (
+	logCredentialsErrors = false
+	strictModules        = false
+	archOverride         = os.Getenv(cs.ArchEnv)
+
+	// registrationServer describes the registration server which the
+	// products were last requested from, including its type.
+	registrationServer = "none"
+)
 
 func init() {
 	value := os.Getenv("CONTAINER_SUSECONNECT_LOG_CREDENTIALS_ERR")
@@ -39,6 +49,11 @@ func init() {
 		logCredentialsErrors = true
 	}
 
+	strict, err := strconv.ParseBool(os.Getenv("CONTAINER_SUSECONNECT_STRICT"))
+	if err == nil && strict {
+		strictModules = true
+	}
+
 	flag.BoolFunc("version", "print version and exit", func(string) error {
 		fmt.Println(cs.GetVersion())
 		os.Exit(0)
@@ -50,6 +65,13 @@ func init() {
 		return nil
 	})
 
+	flag.BoolFunc("strict", "fail on unknown modules instead of warning about them", func(string) error {
+		strictModules = true
+		return nil
+	})
+
+	flag.StringVar(&archOverride, "arch", archOverride, "request the repositories for this architecture instead of the one of the base product")
+
 	flag.Usage = func() {
 		fmt.Fprintf(flag.CommandLine.Output(),
 			"container-suseconnect: Access zypper repositories from within containers"+
@@ -69,9 +91,42 @@ their 'Identifier' can be used to enable them via the ADDITIONAL_MODULES
 environment variable during container creation/run. When enabling multiple
 modules the identifiers are expected to be comma-separated.
 
+Repositories of the enabled modules can be enabled or disabled by name with the
+ENABLE_REPOSITORIES and DISABLE_REPOSITORIES environment variables, which take
+comma-separated glob patterns (e.g. '*-Debuginfo-*').
+
+The aliases of the repositories can be prefixed with CONTAINER_SUSECONNECT_ALIAS
+(e.g. 'suse-'), or built from a template where '{name}' is replaced by the name
+of the repository. Aliases already used in /etc/zypp/repos.d are reported.
+
+Recommended modules are skipped when CONTAINER_SUSECONNECT_MINIMAL=true, so only
+the base product and the requested modules are given to zypper.
+
+Unknown modules requested by the user are reported with close matches of
+their identifier. With '--strict' (or CONTAINER_SUSECONNECT_STRICT=true) they
+make the 'zypper' and 'list-modules' subcommands fail.
+
+Products and repositories can be blocked by the policy files in
+/etc/container-suseconnect/policy.conf, /run/secrets/container-suseconnect-policy
+and the one given by CONTAINER_SUSECONNECT_POLICY, which are all enforced.
+
+The repositories are requested for the architecture of the base product of
+the image, unless another one is given with '--arch' or CONTAINER_SUSECONNECT_ARCH
+(e.g. on multi-arch builds). Mismatches with TARGETARCH or with the architecture
+container-suseconnect runs as are reported.
+
 The 'z|zypp|zypper' subcommand runs the application as zypper plugin and is only
 intended to use for debugging purposes.
 
+The 'plan [--json]' subcommand prints the repositories which the zypper plugin
+would add, remove or change compared to the ones currently configured in the
+container, e.g. before changing the credentials of the host.
+
+The 'scrub [--verify]' subcommand removes everything container-suseconnect
+added to the system, and it is meant to be the last step of a build. With
+'--verify' the filesystem is scanned for leftover credentials afterwards, and
+the application exits with 1 if any is found.
+
 `)
 		flag.PrintDefaults()
 	}
@@ -99,6 +154,10 @@ func main() {
 			appAction = runZypperURLResolver
 		case "z", "zypp", "zypper":
 			appAction = runZypperPlugin
+		case "scrub":
+			appAction = runScrub
+		case "plan":
+			appAction = runPlan
 		default:
 			flag.Usage()
 			os.Exit(1)
@@ -107,6 +166,7 @@ func main() {
 
 	// Run the application with the selected action.
 	cs.SetLoggerOutput()
+	configureSideEffects()
 	if err := appAction(); err != nil {
 		if !cs.IsCredentialsNotFoundError(err) || logCredentialsErrors {
 			log.Fatal(err)
@@ -114,11 +174,62 @@ func main() {
 	}
 }
 
+// configureSideEffects redirects or skips the changes on the system which
+// cannot be done on read-only root filesystems or as a non-root user, logging
+// the features that are degraded because of it.
+func configureSideEffects() {
+	dir := cs.WritableDir()
+	if dir != "" {
+		log.Printf("Redirecting all changes on the system to %s", dir)
+	}
+
+	for _, feature := range regionsrv.ConfigureSideEffects(dir) {
+		log.Printf("Degraded: %s", feature)
+	}
+}
+
 // requestProducts collects a slice of products for the currently available
-// environment
-func requestProducts() ([]cs.Product, error) {
+// environment, with the URLs of their repositories rewritten as configured.
+// When `updateHosts` is true, that is, when zypper itself uses the
+// repositories, they reference a zypp credentials file generated from the
+// credentials actually used.
+func requestProducts(updateHosts bool) ([]cs.Product, error) {
+	rewrites := cs.URLRewrites{}
+	if err := cs.ReadConfiguration(&rewrites); err != nil {
+		return nil, fmt.Errorf("could not read the URL rewrites: %v", err)
+	}
+
+	products, credentials, err := requestServerProducts(updateHosts)
+	if err != nil {
+		return nil, err
+	}
+
+	rewrites.Apply(products)
+
+	// zypper has to authenticate with the credentials actually used, which
+	// may not be in any file within the container. This is only needed when
+	// some repositories authenticate against the registration server.
+	if updateHosts && cs.UsesCredentialsFile(products) {
+		if name, err := cs.WriteCredentialsFile(credentials); err != nil {
+			log.Printf("Warning: could not write the zypp credentials file: %v\n", err)
+		} else {
+			cs.UseCredentialsFile(products, name)
+		}
+	}
+
+	return products, nil
+}
+
+// requestServerProducts requests the products from the registration server
+// of the currently available environment. Requests against the update
+// servers given by containerbuild-regionsrv are pinned to their IP addresses,
+// so the hosts file is only updated when `updateHosts` is true, that is, when
+// zypper itself has to reach these servers. The credentials used are returned
+// along with the products.
+func requestServerProducts(updateHosts bool) ([]cs.Product, cs.Credentials, error) {
 	credentials := cs.Credentials{}
 	suseConnectData := cs.SUSEConnectData{}
+	var servers []regionsrv.UpdateServer
 
 	// read config from "containerbuild-regionsrv" service, if that service is
 	// running, we're running inside a public cloud instance in that case read
@@ -128,87 +239,217 @@ func requestProducts() ([]cs.Product, error) {
 
 		cloudCfg, err := regionsrv.ReadConfigFromServer()
 		if err != nil {
-			return nil, err
+			return nil, credentials, err
 		}
 
 		credentials.Username = cloudCfg.Username
 		credentials.Password = cloudCfg.Password
 		credentials.InstanceData = cloudCfg.InstanceData
 
-		suseConnectData.SccURL = "https://" + cloudCfg.ServerFqdn
 		suseConnectData.Insecure = false
+		// The CA may not be installed into the trust store, e.g. with
+		// CONTAINER_SUSECONNECT_WRITABLE_DIR or when running rootless.
+		suseConnectData.CA = cloudCfg.Ca
 
 		if cloudCfg.Ca != "" {
-			regionsrv.SaveCAFile(cloudCfg.Ca)
+			if err := regionsrv.SaveCAFile(cloudCfg.Ca); err != nil {
+				return nil, credentials, fmt.Errorf("could not save the CA from containerbuild-regionsrv: %v", err)
+			}
 		}
 
-		regionsrv.UpdateHostsFile(cloudCfg.ServerFqdn, cloudCfg.ServerIP)
+		servers = cloudCfg.UpdateServers()
+		if len(servers) == 0 {
+			return nil, credentials, fmt.Errorf("no update server given by containerbuild-regionsrv")
+		}
 	} else {
 		if err := cs.ReadConfiguration(&credentials); err != nil {
-			return nil, err
+			return nil, credentials, err
 		}
 
 		if err := cs.ReadConfiguration(&suseConnectData); err != nil {
-			return nil, err
+			return nil, credentials, err
 		}
 	}
 
 	installedProduct, err := cs.GetInstalledProduct()
 	if err != nil {
-		return nil, err
+		return nil, credentials, err
 	}
 
 	log.Printf("Installed product: %v\n", installedProduct)
-	log.Printf("Registration server set to %v\n", suseConnectData.SccURL)
+	installedProduct = cs.ResolveArch(installedProduct, archOverride)
 
-	products, err := cs.RequestProducts(suseConnectData, credentials, installedProduct)
-	if err != nil {
-		return nil, err
+	if len(servers) == 0 {
+		setRegistrationServer(&suseConnectData)
+		products, err := cs.RequestProducts(suseConnectData, credentials, installedProduct)
+		return products, credentials, err
 	}
 
-	return products, nil
+	// Fail over between the update servers of the region, in order of
+	// preference.
+	for _, server := range servers {
+		suseConnectData.SccURL = "https://" + server.Fqdn
+		suseConnectData.PinnedHosts = map[string]string{server.Fqdn: server.IP}
+		suseConnectData.ServerType = cs.ServerTypeUnknown
+		setRegistrationServer(&suseConnectData)
+
+		if updateHosts {
+			if err := regionsrv.UpdateHostsFile(server.Fqdn, server.IP); err != nil {
+				log.Printf("Warning: %v\n", err)
+			}
+		}
+
+		var products []cs.Product
+		products, err = cs.RequestProducts(suseConnectData, credentials, installedProduct)
+		if err == nil {
+			return products, credentials, nil
+		}
+
+		log.Printf("Could not retrieve products from %v: %v\n", server.Fqdn, err)
+	}
+
+	return nil, credentials, err
 }
 
-// Read the arguments as given by zypper on the stdin and print into stdout the
-// response to be used.
+// runZypperURLResolver runs the application as a zypper URL resolver plugin,
+// answering the requests given by zypper on the stdin until it disconnects.
+// Paths are resolved against the update server given by the
+// containerbuild-regionsrv service if it is reachable, and against the
+// registration server from the regular configuration files otherwise.
 func runZypperURLResolver() error {
+	resolve := regionsrv.ResolveFromServer
+
+	if regionsrv.CachedConfigAvailable() {
+		return regionsrv.ServeURLResolver(os.Stdin, os.Stdout, resolve)
+	}
+
 	if err := regionsrv.ServerReachable(); err != nil {
-		return fmt.Errorf("could not reach build server from the host: %v", err)
+		log.Printf("Could not reach build server from the host (%v), "+
+			"resolving against the registration server\n", err)
+		resolve = resolveFromConfiguration()
 	}
 
-	input, err := regionsrv.ParseStdin()
-	if err != nil {
-		return fmt.Errorf("could not parse input: %s", err)
+	return regionsrv.ServeURLResolver(os.Stdin, os.Stdout, resolve)
+}
+
+// resolveFromConfiguration returns a resolver function which resolves paths
+// against the registration server as given by the `Credentials` and the
+// `SUSEConnectData` configuration. Errors while reading the configuration are
+// reported back to zypper on each request.
+func resolveFromConfiguration() regionsrv.ResolveFunc {
+	credentials := cs.Credentials{}
+	suseConnectData := cs.SUSEConnectData{}
+
+	cfgErr := cs.ReadConfiguration(&credentials)
+	if cfgErr == nil {
+		cfgErr = cs.ReadConfiguration(&suseConnectData)
+	}
+
+	return func(params map[string]string) (*regionsrv.Frame, error) {
+		if cfgErr != nil {
+			return nil, cfgErr
+		}
+
+		u, err := cs.ResolveRepositoryURL(suseConnectData, credentials, params["path"])
+		if err != nil {
+			return nil, err
+		}
+
+		return regionsrv.NewResolvedURLFrame(u, nil), nil
 	}
+}
+
+// setRegistrationServer detects the type of the registration server given by
+// `data`, unless it is set in the SUSEConnect file, and it reports it.
+func setRegistrationServer(data *cs.SUSEConnectData) {
+	data.ServerType = cs.DetectServerType(*data)
+	registrationServer = fmt.Sprintf("%v (%v)", data.SccURL, data.ServerType)
 
-	return regionsrv.PrintResponse(input)
+	log.Printf("Registration server set to %v\n", registrationServer)
 }
 
 // runZypperPlugin runs the application in zypper plugin mode, which dumps
 // all available repositories for the installed product. Additional modules
 // can be specified via the `ADDITIONAL_MODULES` environment variable, which
-// reflect the module `identifier`.
+// reflect the module `identifier`. The attributes of the repositories can be
+// overridden through the repository rules file, and the policy files can
+// block products and repositories.
 func runZypperPlugin() error {
-	products, err := requestProducts()
+	if err := cs.LoadPolicy(); err != nil {
+		return fmt.Errorf("could not read the policy: %v", err)
+	}
+
+	products, err := requestProducts(true)
 	if err != nil {
 		return err
 	}
 
-	for _, product := range products {
-		cs.DumpRepositories(os.Stdout, product)
+	if err := cs.CheckRequestedModules(products, strictModules); err != nil {
+		return err
+	}
+
+	rules := cs.RepositoryRules{}
+	if err := cs.ReadConfiguration(&rules); err != nil {
+		return fmt.Errorf("could not read the repository rules: %v", err)
 	}
+	rules.Apply(products)
+
+	var output bytes.Buffer
+	cs.DumpProducts(io.MultiWriter(os.Stdout, &output), products)
+	cs.SaveLastPluginOutput(output.Bytes())
 
 	return nil
 }
 
+// runPlan prints the differences between the repositories currently
+// configured in the container and the ones the zypper plugin would give now,
+// either in a human readable way or as JSON.
+func runPlan() error {
+	flags := flag.NewFlagSet("plan", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print the changes as JSON")
+	flags.Parse(flag.Args()[1:])
+
+	if err := cs.LoadPolicy(); err != nil {
+		return fmt.Errorf("could not read the policy: %v", err)
+	}
+
+	products, err := requestProducts(false)
+	if err != nil {
+		return err
+	}
+
+	rules := cs.RepositoryRules{}
+	if err := cs.ReadConfiguration(&rules); err != nil {
+		return fmt.Errorf("could not read the repository rules: %v", err)
+	}
+	rules.Apply(products)
+
+	plan := cs.PlanRepositories(products)
+	if *asJSON {
+		return plan.WriteJSON(os.Stdout)
+	}
+
+	plan.WriteText(os.Stdout)
+	return nil
+}
+
 // runListModules lists all available modules and their metadata, which
-// includes the `Name`, `Identifier` and the `Recommended` flag.
+// includes the `Name`, `Identifier` and the `Recommended` flag. Modules
+// blocked by the policy files are not listed.
 func runListModules() error {
-	products, err := requestProducts()
+	if err := cs.LoadPolicy(); err != nil {
+		return fmt.Errorf("could not read the policy: %v", err)
+	}
+
+	products, err := requestProducts(false)
 	if err != nil {
 		return err
 	}
 
+	if err := cs.CheckRequestedModules(products, strictModules); err != nil {
+		return err
+	}
+
 	fmt.Printf("All available modules:\n\n")
 	cs.ListModules(os.Stdout, products)
 
@@ -217,13 +458,66 @@ func runListModules() error {
 
 // runListProducts lists all available products and their metadata
 func runListProducts() error {
-	products, err := requestProducts()
+	products, err := requestProducts(false)
 	if err != nil {
 		return err
 	}
 
+	fmt.Printf("Registration server: %v\n\n", registrationServer)
 	fmt.Printf("All available products:\n\n")
 	cs.ListProducts(os.Stdout, products, "none")
 
 	return nil
 }
+
+// runScrub removes every trace of container-suseconnect from the system, and
+// optionally scans the filesystem for leftover credentials afterwards.
+func runScrub() error {
+	flags := flag.NewFlagSet("scrub", flag.ExitOnError)
+	verify := flags.Bool("verify", false, "scan the filesystem for leftover credentials")
+	flags.Parse(flag.Args()[1:])
+
+	steps := []struct {
+		name   string
+		action func() error
+	}{
+		{"CA of the update server", regionsrv.RemoveCAFile},
+		{"hosts file entries", regionsrv.RemoveHostsEntries},
+		{"last output of the zypper plugin", cs.RemoveLastPluginOutput},
+		{"generated credentials file", cs.RemoveCredentialsFile},
+		{"cached type of the registration server", cs.RemoveCachedServerType},
+		{"cached configuration", regionsrv.RemoveRuntimeFiles},
+		{"repositories of the service", cs.RemoveServiceRepositories},
+		{"log file", cs.RemoveLogFile},
+	}
+
+	failed := false
+	for _, step := range steps {
+		if err := step.action(); err != nil {
+			log.Printf("Could not remove the %s: %v\n", step.name, err)
+			failed = true
+		}
+	}
+	if failed {
+		return fmt.Errorf("could not scrub the system")
+	}
+
+	if !*verify {
+		return nil
+	}
+
+	leftovers, err := cs.FindLeftovers("/")
+	if err != nil {
+		return err
+	}
+	leftovers = append(regionsrv.Leftovers(), leftovers...)
+
+	for _, leftover := range leftovers {
+		fmt.Println(leftover)
+	}
+	if len(leftovers) > 0 {
+		return fmt.Errorf("found %d leftovers of credentials in the filesystem", len(leftovers))
+	}
+
+	return nil
+}
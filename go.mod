@@ -2,10 +2,7 @@ module github.com/SUSE/container-suseconnect
 
 go 1.24.0
 
-require (
-	github.com/mssola/capture v1.1.0
-	github.com/stretchr/testify v1.11.1
-)
+require github.com/stretchr/testify v1.11.1
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
@@ -0,0 +1,102 @@
+// Copyright (c) 2026 SUSE LLC. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containersuseconnect
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// AliasEnv is the environment variable used to specify how the aliases of the
+// repositories are built from their names. It is either a template where
+// `{name}` is replaced by the name of the repository (e.g. `suse-{name}`), or
+// a prefix for it.
+const AliasEnv = "CONTAINER_SUSECONNECT_ALIAS"
+
+// aliasPlaceholder is replaced by the name of the repository in the alias
+// template.
+const aliasPlaceholder = "{name}"
+
+// repositoryAlias returns the alias under which the repository with the given
+// name is given to zypper.
+func repositoryAlias(name string) string {
+	template := strings.TrimSpace(os.Getenv(AliasEnv))
+	if !strings.Contains(template, aliasPlaceholder) {
+		return template + name
+	}
+
+	return strings.ReplaceAll(template, aliasPlaceholder, name)
+}
+
+// serviceAlias returns the alias under which zypper stores the repository
+// with the given alias, since it prefixes the repositories of a service with
+// the alias of the service.
+func serviceAlias(alias string) string {
+	return ServiceName + ":" + alias
+}
+
+// existingRepositories returns the repositories defined in the repository
+// files of zypper. The repositories of the container-suseconnect service are
+// not taken into account, since they are the ones being dumped.
+func existingRepositories() []repoSection {
+	var sections []repoSection
+
+	paths, err := filepath.Glob(filepath.Join(reposDir, "*.repo"))
+	if err != nil {
+		return sections
+	}
+
+	for _, path := range paths {
+		if serviceRepository(path) {
+			continue
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+
+		sections = append(sections, parseRepoSections(file, path)...)
+		file.Close()
+	}
+
+	return sections
+}
+
+// reportAliasCollisions logs the given repositories whose alias, as stored by
+// zypper, is already taken by a repository defined in the image, which zypper
+// would otherwise shadow silently. Repositories of the image pointing to the
+// same location as one of the given repositories are reported too, since
+// zypper would refresh and use both of them.
+func reportAliasCollisions(repos []Repository) {
+	existing := existingRepositories()
+
+	for _, repo := range repos {
+		alias := serviceAlias(repositoryAlias(repo.Name))
+		location := strings.TrimSuffix(repositoryLocation(repo), "/")
+
+		for _, section := range existing {
+			if section.alias == alias {
+				log.Printf("Warning: the alias '%v' of repository %v is already used in %v, set %v to avoid the collision",
+					alias, repo.Name, section.source, AliasEnv)
+			} else if baseurl := section.attributes["baseurl"]; baseurl != "" && strings.TrimSuffix(baseurl, "/") == location {
+				log.Printf("Warning: repository %v points to the same URL as the repository '%v' in %v",
+					repo.Name, section.alias, section.source)
+			}
+		}
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2026 SUSE LLC. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containersuseconnect
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRepositoryAlias(t *testing.T) {
+	cases := []struct {
+		template, alias string
+	}{
+		{"", "SLE-Module-Basesystem15-Updates"},
+		{"suse-", "suse-SLE-Module-Basesystem15-Updates"},
+		{"{name}@suse", "SLE-Module-Basesystem15-Updates@suse"},
+	}
+
+	for _, c := range cases {
+		t.Setenv(AliasEnv, c.template)
+		if alias := repositoryAlias("SLE-Module-Basesystem15-Updates"); alias != c.alias {
+			t.Fatalf("Expected alias '%v' for template '%v', got '%v'", c.alias, c.template, alias)
+		}
+	}
+}
+
+func TestDumpProductsAliasCollisions(t *testing.T) {
+	withDropIns(t, nil)
+	t.Setenv("ADDITIONAL_MODULES", "")
+
+	old := reposDir
+	defer func() { reposDir = old }()
+
+	reposDir = t.TempDir()
+	writeScrubFiles(t, reposDir, map[string]string{
+		"local.repo": "[" + ServiceName + ":SLE-Module-Basesystem15-Updates]\nbaseurl=http://example.com/\n\n" +
+			"[" + ServiceName + ":suse-SLE-Product-SLES15-Pool]\nbaseurl=http://example.com/sles/\n\n" +
+			"[SLE-Module-Containers15-Pool]\nbaseurl=http://example.com/containers/\n",
+		"mirror.repo": "[sles-updates]\n" +
+			"baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Updates/SLE-Product-SLES/15/x86_64/update?token\n",
+		"service.repo": "[" + ServiceName + ":SLE-Product-SLES15-Pool]\nservice=" + ServiceName + "\n",
+	})
+
+	products := readProducts(t, "testdata/products-sle15.json")
+
+	prepareLogger()
+	var buf bytes.Buffer
+	DumpProducts(&buf, []Product{products[0]})
+
+	expected := "Warning: the alias '" + ServiceName + ":SLE-Module-Basesystem15-Updates' of repository " +
+		"SLE-Module-Basesystem15-Updates is already used in " + filepath.Join(reposDir, "local.repo")
+	if !strings.Contains(logged.String(), expected) {
+		t.Fatalf("Expected '%v' to be logged, got: %v", expected, logged.String())
+	}
+	if strings.Contains(logged.String(), "SLE-Product-SLES15-Pool") {
+		t.Fatalf("Repositories of the service should not collide, got: %v", logged.String())
+	}
+	if strings.Contains(logged.String(), "Containers15-Pool") {
+		t.Fatalf("Aliases without the prefix of the service should not collide, got: %v", logged.String())
+	}
+
+	expected = "Warning: repository SLE-Product-SLES15-Updates points to the same URL as the repository " +
+		"'sles-updates' in " + filepath.Join(reposDir, "mirror.repo")
+	if !strings.Contains(logged.String(), expected) {
+		t.Fatalf("Expected '%v' to be logged, got: %v", expected, logged.String())
+	}
+
+	t.Setenv(AliasEnv, "suse-")
+
+	prepareLogger()
+	buf.Reset()
+	DumpProducts(&buf, []Product{products[0]})
+
+	if !strings.Contains(buf.String(), "[suse-SLE-Module-Basesystem15-Updates]\n") {
+		t.Fatalf("Expected the repositories to use the alias prefix:\n%v", buf.String())
+	}
+	if strings.Contains(logged.String(), "Basesystem15-Updates") {
+		t.Fatalf("The prefix should avoid the collision, got: %v", logged.String())
+	}
+	if !strings.Contains(logged.String(), "Warning: the alias '"+ServiceName+":suse-SLE-Product-SLES15-Pool'") {
+		t.Fatalf("Expected the prefixed alias to collide, got: %v", logged.String())
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2026 SUSE LLC. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containersuseconnect
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// ArchEnv is the environment variable used to request the repositories for
+// another architecture than the one of the base product.
+const ArchEnv = "CONTAINER_SUSECONNECT_ARCH"
+
+// goArchs maps the architecture names of Go and of container platforms (e.g.
+// `TARGETARCH` from `docker buildx`) to the ones of SUSE products.
+var goArchs = map[string]string{
+	"amd64": "x86_64",
+	"arm64": "aarch64",
+	"arm":   "armv7hl",
+	"386":   "i586",
+}
+
+var (
+	// binaryArch is the architecture this binary was built for.
+	binaryArch = runtime.GOARCH
+
+	// machineArch returns the architecture reported by the kernel, which
+	// is the emulated one under qemu-user.
+	machineArch = unameMachine
+)
+
+// normalizeArch returns the SUSE name of the given architecture.
+func normalizeArch(arch string) string {
+	arch = strings.TrimSpace(arch)
+	if suseArch, ok := goArchs[arch]; ok {
+		return suseArch
+	}
+
+	return arch
+}
+
+// archMismatches returns the descriptions of the architectures of the build
+// which do not match the given one of the base product.
+func archMismatches(arch string) []string {
+	var mismatches []string
+
+	if target := normalizeArch(os.Getenv("TARGETARCH")); target != "" && target != arch {
+		mismatches = append(mismatches, fmt.Sprintf("TARGETARCH is %v", target))
+	}
+
+	running := normalizeArch(binaryArch)
+	if machine := normalizeArch(machineArch()); machine != "" && machine != running {
+		running = fmt.Sprintf("%v (on %v)", running, machine)
+	}
+	if normalizeArch(binaryArch) != arch {
+		mismatches = append(mismatches, fmt.Sprintf("container-suseconnect runs as %v", running))
+	}
+
+	return mismatches
+}
+
+// ResolveArch returns the installed product with the architecture for which
+// the repositories have to be requested. The architecture of the base product
+// is used unless the given `override` is set, e.g. for multi-arch builds where
+// the base product of the image cannot be trusted. Mismatches between the
+// architecture of the base product and the ones of the build (like an x86_64
+// binary running against an aarch64 root filesystem) are reported.
+func ResolveArch(installed InstalledProduct, override string) InstalledProduct {
+	if override = normalizeArch(override); override != "" {
+		if override != installed.Arch {
+			log.Printf("Requesting the repositories for %v instead of %v from the base product", override, installed.Arch)
+			installed.Arch = override
+		}
+		return installed
+	}
+
+	if mismatches := archMismatches(installed.Arch); len(mismatches) > 0 {
+		log.Printf("Warning: the base product is for %v, but %v. Set %v or pass --arch to request the repositories for another architecture",
+			installed.Arch, strings.Join(mismatches, " and "), ArchEnv)
+	}
+
+	return installed
+}
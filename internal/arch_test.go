@@ -0,0 +1,82 @@
+// Copyright (c) 2026 SUSE LLC. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containersuseconnect
+
+import (
+	"strings"
+	"testing"
+)
+
+// withBuildArchs fakes the architecture of the binary and of the machine for
+// the rest of the test.
+func withBuildArchs(t *testing.T, binary, machine string) {
+	origBinary, origMachine := binaryArch, machineArch
+	t.Cleanup(func() {
+		binaryArch, machineArch = origBinary, origMachine
+	})
+
+	binaryArch = binary
+	machineArch = func() string { return machine }
+}
+
+func TestResolveArch(t *testing.T) {
+	withBuildArchs(t, "amd64", "x86_64")
+	t.Setenv("TARGETARCH", "amd64")
+
+	installed := InstalledProduct{Identifier: "SLES", Version: "15.5", Arch: "x86_64"}
+
+	prepareLogger()
+	if resolved := ResolveArch(installed, ""); resolved != installed {
+		t.Fatalf("Unexpected product: %v", resolved)
+	}
+	if logged.String() != "" {
+		t.Fatalf("Nothing should have been logged, got: %v", logged.String())
+	}
+
+	prepareLogger()
+	if resolved := ResolveArch(installed, "arm64"); resolved.Arch != "aarch64" {
+		t.Fatalf("Expected the overridden architecture, got: %v", resolved)
+	}
+	shouldHaveLogged(t, "Requesting the repositories for aarch64 instead of x86_64 from the base product")
+}
+
+func TestResolveArchMismatch(t *testing.T) {
+	withBuildArchs(t, "amd64", "x86_64")
+	t.Setenv("TARGETARCH", "arm64")
+
+	installed := InstalledProduct{Identifier: "SLES", Version: "15.5", Arch: "aarch64"}
+
+	prepareLogger()
+	if resolved := ResolveArch(installed, ""); resolved != installed {
+		t.Fatalf("The base product should be kept without an override, got: %v", resolved)
+	}
+
+	expected := "Warning: the base product is for aarch64, but container-suseconnect runs as x86_64. Set " + ArchEnv
+	if !strings.Contains(logged.String(), expected) {
+		t.Fatalf("Expected '%v' to be logged, got: %v", expected, logged.String())
+	}
+
+	installed.Arch = "x86_64"
+	withBuildArchs(t, "arm64", "x86_64")
+
+	prepareLogger()
+	ResolveArch(installed, "")
+
+	expected = "Warning: the base product is for x86_64, but TARGETARCH is aarch64 and " +
+		"container-suseconnect runs as aarch64 (on x86_64)."
+	if !strings.Contains(logged.String(), expected) {
+		t.Fatalf("Expected '%v' to be logged, got: %v", expected, logged.String())
+	}
+}
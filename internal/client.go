@@ -0,0 +1,75 @@
+// Copyright (c) 2026 SUSE LLC. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containersuseconnect
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"log"
+	"net"
+	"net/http"
+	"time"
+)
+
+// newHTTPClient returns the HTTP client to be used against the registration
+// server described by `data`. Connections to the hosts in `data.PinnedHosts`
+// go to the pinned IP address instead of the one given by the name resolution
+// of the system. The TLS handshake still verifies the original hostname, also
+// against `data.CA` if given.
+func newHTTPClient(data SUSEConnectData) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+
+	return &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: data.Insecure,
+				RootCAs:            rootCAs(data.CA),
+			},
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				if host, port, err := net.SplitHostPort(addr); err == nil {
+					if ip, ok := data.PinnedHosts[host]; ok {
+						addr = net.JoinHostPort(ip, port)
+					}
+				}
+
+				return dialer.DialContext(ctx, network, addr)
+			},
+		},
+	}
+}
+
+// rootCAs returns the system certificates together with the given PEM-encoded
+// CA, or nil to only use the system ones.
+func rootCAs(ca string) *x509.CertPool {
+	if ca == "" {
+		return nil
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM([]byte(ca)) {
+		log.Printf("Warning: could not parse the CA of the registration server")
+		return nil
+	}
+
+	return pool
+}
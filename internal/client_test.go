@@ -0,0 +1,92 @@
+// Copyright (c) 2026 SUSE LLC. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containersuseconnect
+
+import (
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestPinnedHosts(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		file, err := os.Open("testdata/subscriptions.json")
+		if err != nil {
+			fmt.Fprintln(w, "FAIL!")
+			return
+		}
+		io.Copy(w, file)
+		file.Close()
+	}))
+	defer ts.Close()
+
+	_, port, _ := net.SplitHostPort(ts.Listener.Addr().String())
+
+	// The hostname does not resolve, so the request only succeeds if the
+	// connection goes to the pinned IP.
+	var cr Credentials
+	data := SUSEConnectData{
+		SccURL:      "http://pinned.invalid:" + port,
+		Insecure:    true,
+		PinnedHosts: map[string]string{"pinned.invalid": "127.0.0.1"},
+	}
+
+	codes, err := requestRegcodes(data, cr)
+	if err != nil {
+		t.Fatalf("It should've run just fine: %v", err)
+	}
+
+	if len(codes) != 1 || codes[0] != "35098ff7" {
+		t.Fatalf("Got the wrong registration codes: %v", codes)
+	}
+}
+
+func TestPinnedHostsWithCA(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"regcode": "35098ff7"}]`)
+	}))
+	defer ts.Close()
+
+	_, port, _ := net.SplitHostPort(ts.Listener.Addr().String())
+
+	// The certificate of the test server is valid for example.com, but it is
+	// not signed by any CA of the system.
+	var cr Credentials
+	data := SUSEConnectData{
+		SccURL:      "https://example.com:" + port,
+		PinnedHosts: map[string]string{"example.com": "127.0.0.1"},
+	}
+
+	prepareLogger()
+	if _, err := requestRegcodes(data, cr); err == nil {
+		t.Fatal("The certificate of the server should not be trusted")
+	}
+
+	data.CA = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw}))
+
+	codes, err := requestRegcodes(data, cr)
+	if err != nil {
+		t.Fatalf("It should've run just fine: %v", err)
+	}
+
+	if len(codes) != 1 || codes[0] != "35098ff7" {
+		t.Fatalf("Got the wrong registration codes: %v", codes)
+	}
+}
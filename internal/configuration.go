@@ -48,6 +48,14 @@ type Configuration interface {
 	afterParseCheck() error
 }
 
+// The sectioned interface is implemented by configurations which are split
+// in sections, where each section starts with a line like '[name]'.
+type sectioned interface {
+	// Called when a new section starts, with the already trimmed name of
+	// the section.
+	setSection(name string)
+}
+
 // From the given slice of locations, return the first location that actually
 // exists on the system. It returns an empty string on error.
 func getLocationPath(locations []string) string {
@@ -97,6 +105,16 @@ func parse(config Configuration, reader io.Reader) error {
 		}
 
 		line := scanner.Text()
+
+		// Section headers, only for configurations supporting them.
+		if sc, ok := config.(sectioned); ok {
+			trimmed := strings.TrimSpace(line)
+			if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+				sc.setSection(strings.TrimSpace(trimmed[1 : len(trimmed)-1]))
+				continue
+			}
+		}
+
 		// Each line should be constructed as 'key' 'separator' 'value'.
 		parts := strings.SplitN(line, string(config.separator()), 2)
 
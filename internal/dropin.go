@@ -0,0 +1,95 @@
+// Copyright (c) 2026 SUSE LLC. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containersuseconnect
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var dropInDir = "/etc/container-suseconnect.d"
+
+// moduleDropIns has the modules enabled by the drop-in files, which allow
+// images to declare the modules they need once, so derived images and
+// containers get the same repositories. Each file can contain a line like:
+//
+//	modules=sle-module-development-tools,!sle-module-legacy
+//
+// Modules prefixed with `!` are removed from the ones enabled by previous
+// files. Files are read in lexical order.
+type moduleDropIns struct {
+	enabled map[string]bool
+}
+
+func (md *moduleDropIns) separator() byte {
+	return '='
+}
+
+func (md *moduleDropIns) locations() []string {
+	return nil
+}
+
+func (md *moduleDropIns) onLocationsNotFound() bool {
+	return true
+}
+
+func (md *moduleDropIns) setValues(key, value string) {
+	switch key {
+	case "modules":
+		for _, identifier := range strings.Split(value, ",") {
+			identifier = strings.TrimSpace(identifier)
+
+			if removed, ok := strings.CutPrefix(identifier, "!"); ok {
+				delete(md.enabled, strings.TrimSpace(removed))
+			} else if identifier != "" {
+				md.enabled[identifier] = true
+			}
+		}
+	default:
+		log.Printf("Warning: Unknown key '%v'", key)
+	}
+}
+
+func (md *moduleDropIns) afterParseCheck() error {
+	return nil
+}
+
+// readModuleDropIns returns the modules enabled by the drop-in files. Files
+// which cannot be read or parsed are skipped.
+func readModuleDropIns() map[string]bool {
+	md := &moduleDropIns{enabled: map[string]bool{}}
+
+	paths, err := filepath.Glob(filepath.Join(dropInDir, "*.conf"))
+	if err != nil {
+		return md.enabled
+	}
+
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			log.Printf("Warning: skipping %v: %v", path, err)
+			continue
+		}
+
+		if err := parse(md, file); err != nil {
+			log.Printf("Warning: skipping the rest of %v", path)
+		}
+		file.Close()
+	}
+
+	return md.enabled
+}
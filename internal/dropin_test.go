@@ -0,0 +1,72 @@
+// Copyright (c) 2026 SUSE LLC. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containersuseconnect
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// withDropIns points the drop-in directory to a temporary one with the given
+// files until the test finishes.
+func withDropIns(t *testing.T, files map[string]string) {
+	old := dropInDir
+	t.Cleanup(func() { dropInDir = old })
+
+	dropInDir = t.TempDir()
+	writeScrubFiles(t, dropInDir, files)
+}
+
+func TestReadModuleDropIns(t *testing.T) {
+	prepareLogger()
+	withDropIns(t, map[string]string{
+		"10-base.conf":    "# Needed by the base image\nmodules=sle-module-legacy, sle-module-containers\n",
+		"20-derived.conf": "modules=!sle-module-legacy,sle-module-development-tools\n",
+		"30-broken.conf":  "modules\n",
+		"README":          "modules=sle-module-ignored\n",
+	})
+
+	enabled := readModuleDropIns()
+	if len(enabled) != 2 || !enabled["sle-module-containers"] || !enabled["sle-module-development-tools"] {
+		t.Fatalf("Unexpected modules: %v", enabled)
+	}
+
+	if !strings.Contains(logged.String(), "skipping the rest of") {
+		t.Fatalf("Expected the broken file to be logged, got: %v", logged.String())
+	}
+}
+
+func TestServiceOutputWithDropIns(t *testing.T) {
+	prepareLogger()
+	withDropIns(t, map[string]string{
+		"10-base.conf":   "modules=sle-module-containers\n",
+		"30-broken.conf": "modules\n",
+	})
+
+	products := readProducts(t, "testdata/products-sle15.json")
+
+	buffer := bytes.Buffer{}
+	DumpProducts(&buffer, []Product{products[0]})
+
+	if !strings.Contains(buffer.String(), "[SLE-Module-Containers15-Pool]") {
+		t.Fatalf("The repositories of the module should be dumped:\n%v", buffer.String())
+	}
+
+	// The drop-ins are read once for all the products and extensions.
+	if count := strings.Count(logged.String(), "skipping the rest of"); count != 1 {
+		t.Fatalf("Expected the broken file to be reported once, got %d times: %v", count, logged.String())
+	}
+}
@@ -34,6 +34,9 @@ const (
 	// RepositoryError indicates that there is something wrong with the
 	// repository that the subscription server gave us
 	RepositoryError
+	// ConfigurationError indicates an invalid configuration file of
+	// container-suseconnect itself
+	ConfigurationError
 )
 
 // SuseConnectError is a custom error type allowing us to distinguish between
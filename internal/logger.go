@@ -29,11 +29,29 @@ const DefaultLogPath = "/var/log/suseconnect.log"
 // Environment variable used to specify a custom path for the log file.
 const LogEnv = "SUSECONNECT_LOG_FILE"
 
+// logFileName is the name of the log file when it is redirected to
+// WritableDir.
+const logFileName = "suseconnect.log"
+
 // getLogEnv returns the value set to the [LogEnv] environment variable.
 func getLogEnv() string {
 	return strings.TrimSpace(os.Getenv(LogEnv))
 }
 
+// getLogPath returns the path of the log file, which is either given through
+// [LogEnv], located inside of [WritableDir] or the [DefaultLogPath].
+func getLogPath() string {
+	if path := getLogEnv(); len(path) != 0 {
+		return path
+	}
+
+	if dir := WritableDir(); len(dir) != 0 {
+		return filepath.Join(dir, logFileName)
+	}
+
+	return DefaultLogPath
+}
+
 // getLogWriter checks if the path can be open and written to
 // and returns an [io.WriteCloser] if there are no errors.
 func getLogWriter(path string) (io.WriteCloser, error) {
@@ -77,12 +95,7 @@ func SetLoggerOutput() {
 	// ensure we are logging to stderr and nowhere else
 	log.SetOutput(os.Stderr)
 
-	path := getLogEnv()
-
-	if len(path) == 0 {
-		path = DefaultLogPath
-	}
-
+	path := getLogPath()
 	w, err := getLogWriter(path)
 
 	if err == nil {
@@ -144,6 +144,18 @@ func TestGetLogEnvTrimSpaces(t *testing.T) {
 	assert.Equal(t, "/path/file.log", envPath)
 }
 
+func TestGetLogPath(t *testing.T) {
+	t.Setenv(LogEnv, "")
+	t.Setenv(WritableDirEnv, "")
+	assert.Equal(t, DefaultLogPath, getLogPath())
+
+	t.Setenv(WritableDirEnv, "/writable")
+	assert.Equal(t, "/writable/suseconnect.log", getLogPath())
+
+	t.Setenv(LogEnv, "/path/file.log")
+	assert.Equal(t, "/path/file.log", getLogPath())
+}
+
 // Ensures that the log is always written to a file and Stderr.
 func TestSetLoggerOutput(t *testing.T) {
 	// ensure no variable is set
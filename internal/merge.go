@@ -0,0 +1,110 @@
+// Copyright (c) 2026 SUSE LLC. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containersuseconnect
+
+import (
+	"log"
+	"net/url"
+	"sort"
+)
+
+// productKey identifies a product regardless of the subscription it was
+// given by.
+func productKey(product Product) string {
+	return product.Identifier + "/" + product.Version + "/" + product.Arch
+}
+
+// repositoryLocation returns the URL of the given repository without its
+// query, which holds authentication tokens that differ between subscriptions.
+func repositoryLocation(repo Repository) string {
+	u, err := url.Parse(repo.URL)
+	if err != nil {
+		return repo.URL
+	}
+
+	u.RawQuery = ""
+	return u.String()
+}
+
+// mergeRepositories appends the repositories from `src` into `dst`, skipping
+// the ones which are already there. If two repositories with the same name
+// point to different locations, the one with the smallest URL is kept, so
+// the result does not depend on the order of the given repositories.
+func mergeRepositories(dst, src []Repository) []Repository {
+	index := map[string]int{}
+	for i, repo := range dst {
+		index[repo.Name] = i
+	}
+
+	for _, repo := range src {
+		i, ok := index[repo.Name]
+		if !ok {
+			index[repo.Name] = len(dst)
+			dst = append(dst, repo)
+			continue
+		}
+
+		if repositoryLocation(dst[i]) != repositoryLocation(repo) {
+			log.Printf("Warning: conflicting locations for repository '%s': %s and %s",
+				repo.Name, repositoryLocation(dst[i]), repositoryLocation(repo))
+		}
+		if repo.URL < dst[i].URL {
+			dst[i] = repo
+		}
+	}
+
+	return dst
+}
+
+// mergeProducts merges the products which are the same (e.g. given by several
+// subscriptions) into a single one, keeping the order in which they first
+// appeared.
+func mergeProducts(products []Product) []Product {
+	var merged []Product
+	index := map[string]int{}
+
+	for _, product := range products {
+		i, ok := index[productKey(product)]
+		if !ok {
+			index[productKey(product)] = len(merged)
+			product.Repositories = mergeRepositories(nil, product.Repositories)
+			product.Extensions = mergeProducts(product.Extensions)
+			merged = append(merged, product)
+			continue
+		}
+
+		dst := &merged[i]
+		dst.Recommended = dst.Recommended || product.Recommended
+		dst.Repositories = mergeRepositories(append([]Repository{}, dst.Repositories...), product.Repositories)
+
+		extensions := append(append([]Product{}, dst.Extensions...), product.Extensions...)
+		dst.Extensions = mergeProducts(extensions)
+	}
+
+	return merged
+}
+
+// MergeProducts merges the product trees given by several subscriptions, so
+// each product and each of its repositories is only present once. The
+// resulting products are sorted by their identifier, version and
+// architecture.
+func MergeProducts(products []Product) []Product {
+	merged := mergeProducts(products)
+	sort.SliceStable(merged, func(i, j int) bool {
+		return productKey(merged[i]) < productKey(merged[j])
+	})
+
+	return merged
+}
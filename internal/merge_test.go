@@ -0,0 +1,122 @@
+// Copyright (c) 2026 SUSE LLC. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containersuseconnect
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+// readProducts parses the products from the given test file.
+func readProducts(t *testing.T, path string) []Product {
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Could not open %v: %v", path, err)
+	}
+	defer file.Close()
+
+	products, err := parseProducts(file)
+	if err != nil {
+		t.Fatalf("Could not parse %v: %v", path, err)
+	}
+
+	return products
+}
+
+// reversed returns a copy of the given products where the products, their
+// repositories and their extensions are in reverse order.
+func reversed(products []Product) []Product {
+	var result []Product
+	for i := len(products) - 1; i >= 0; i-- {
+		product := products[i]
+
+		var repos []Repository
+		for j := len(product.Repositories) - 1; j >= 0; j-- {
+			repos = append(repos, product.Repositories[j])
+		}
+		product.Repositories = repos
+		product.Extensions = reversed(product.Extensions)
+
+		result = append(result, product)
+	}
+
+	return result
+}
+
+func TestMergeProducts(t *testing.T) {
+	sle12 := readProducts(t, "testdata/products-sle12.json")
+	sle15 := readProducts(t, "testdata/products-sle15.json")
+
+	products := MergeProducts(append(append(sle15, sle12...), sle15...))
+	if len(products) != 2 {
+		t.Fatalf("Unexpected number of products found. Got %d, expected %d", len(products), 2)
+	}
+
+	productHelperSLE12(t, products[0])
+	productHelper(t, products[1], "15")
+
+	if len(products[1].Extensions) != len(sle15[0].Extensions) {
+		t.Fatalf("Extensions should have been merged, got %d", len(products[1].Extensions))
+	}
+	if len(products[1].Repositories) != len(sle15[0].Repositories) {
+		t.Fatalf("Repositories should have been merged, got %d", len(products[1].Repositories))
+	}
+}
+
+func TestMergeRepositoriesConflict(t *testing.T) {
+	prepareLogger()
+
+	repos := mergeRepositories(nil, []Repository{
+		{Name: "repo", URL: "https://smt.test.lan/repo?token-b"},
+		{Name: "repo", URL: "https://smt.test.lan/repo?token-a"},
+		{Name: "other", URL: "https://smt.test.lan/other"},
+	})
+
+	if len(repos) != 2 || repos[0].URL != "https://smt.test.lan/repo?token-a" {
+		t.Fatalf("Unexpected repositories: %v", repos)
+	}
+	if logged.Len() != 0 {
+		t.Fatalf("Different tokens are not a conflict: %v", logged.String())
+	}
+
+	repos = mergeRepositories(repos, []Repository{{Name: "other", URL: "https://rmt.test.lan/other"}})
+	if len(repos) != 2 || repos[1].URL != "https://rmt.test.lan/other" {
+		t.Fatalf("Unexpected repositories: %v", repos)
+	}
+	if !strings.Contains(logged.String(), "conflicting locations for repository 'other'") {
+		t.Fatalf("Expected a conflict to be logged, got: %v", logged.String())
+	}
+}
+
+func TestDumpProductsDeterministic(t *testing.T) {
+	sle15 := readProducts(t, "testdata/products-sle15.json")
+
+	var first, second bytes.Buffer
+	DumpProducts(&first, MergeProducts(append(sle15, sle15...)))
+	DumpProducts(&second, MergeProducts(reversed(sle15)))
+
+	if first.String() != second.String() {
+		t.Fatalf("The output depends on the order of the products:\n%v\n---\n%v", first.String(), second.String())
+	}
+
+	if strings.Count(first.String(), "# generated by container-suseconnect") != 1 {
+		t.Fatalf("The header should only be printed once:\n%v", first.String())
+	}
+	if strings.Count(first.String(), "[SLE-Product-SLES15-Pool]\n") != 1 {
+		t.Fatalf("Repositories should only be printed once:\n%v", first.String())
+	}
+}
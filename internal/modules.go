@@ -0,0 +1,145 @@
+// Copyright (c) 2026 SUSE LLC. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containersuseconnect
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// maxSuggestions is the maximum number of close matches suggested for an
+// unknown module identifier.
+const maxSuggestions = 3
+
+// requestedModules returns the identifiers of the modules requested by the
+// user, either through the `ADDITIONAL_MODULES` environment variable or the
+// drop-in files.
+func requestedModules() []string {
+	requested := map[string]bool{}
+	for _, identifier := range patternsFromEnv("ADDITIONAL_MODULES") {
+		requested[identifier] = true
+	}
+	for identifier := range readModuleDropIns() {
+		requested[identifier] = true
+	}
+
+	var identifiers []string
+	for identifier := range requested {
+		identifiers = append(identifiers, identifier)
+	}
+	sort.Strings(identifiers)
+
+	return identifiers
+}
+
+// productIdentifiers adds the identifiers of the given products and all their
+// extensions to the given set.
+func productIdentifiers(products []Product, identifiers map[string]bool) {
+	for _, product := range products {
+		identifiers[product.Identifier] = true
+		productIdentifiers(product.Extensions, identifiers)
+	}
+}
+
+// levenshtein returns the edit distance between the two given strings.
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+
+		prev = cur
+	}
+
+	return prev[len(b)]
+}
+
+// closeMatches returns the identifiers which are close to the given one,
+// sorted by their distance to it.
+func closeMatches(identifier string, identifiers map[string]bool) []string {
+	distances := map[string]int{}
+	maxDistance := max(2, len(identifier)/3)
+
+	for candidate := range identifiers {
+		distance := levenshtein(identifier, candidate)
+		if distance <= maxDistance || strings.Contains(candidate, identifier) {
+			distances[candidate] = distance
+		}
+	}
+
+	var matches []string
+	for candidate := range distances {
+		matches = append(matches, candidate)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if distances[matches[i]] != distances[matches[j]] {
+			return distances[matches[i]] < distances[matches[j]]
+		}
+		return matches[i] < matches[j]
+	})
+
+	if len(matches) > maxSuggestions {
+		matches = matches[:maxSuggestions]
+	}
+
+	return matches
+}
+
+// CheckRequestedModules makes sure that every module requested by the user
+// exists in the given product tree. Unknown modules are reported along with
+// close matches of their identifier. In `strict` mode an error listing them
+// is returned, otherwise only a warning is logged.
+func CheckRequestedModules(products []Product, strict bool) error {
+	identifiers := map[string]bool{}
+	productIdentifiers(products, identifiers)
+
+	var unknown []string
+	for _, identifier := range requestedModules() {
+		if identifiers[identifier] {
+			continue
+		}
+
+		msg := fmt.Sprintf("'%v'", identifier)
+		if matches := closeMatches(identifier, identifiers); len(matches) > 0 {
+			msg += fmt.Sprintf(" (did you mean '%v'?)", strings.Join(matches, "', '"))
+		}
+		unknown = append(unknown, msg)
+	}
+
+	if len(unknown) == 0 {
+		return nil
+	}
+
+	if strict {
+		return loggedError(ConfigurationError, "Unknown modules requested: %v", strings.Join(unknown, ", "))
+	}
+
+	log.Printf("Warning: unknown modules requested: %v", strings.Join(unknown, ", "))
+	return nil
+}
@@ -0,0 +1,74 @@
+// Copyright (c) 2026 SUSE LLC. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containersuseconnect
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLevenshtein(t *testing.T) {
+	cases := []struct {
+		a, b     string
+		distance int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"kitten", "sitting", 3},
+		{"sle-module-containers", "sle-module-containres", 2},
+	}
+
+	for _, c := range cases {
+		if d := levenshtein(c.a, c.b); d != c.distance {
+			t.Fatalf("Expected distance %d between '%v' and '%v', got %d", c.distance, c.a, c.b, d)
+		}
+	}
+}
+
+func TestCheckRequestedModules(t *testing.T) {
+	withDropIns(t, map[string]string{"10-base.conf": "modules=sle-module-legacy\n"})
+	t.Setenv("ADDITIONAL_MODULES", "sle-module-containres,sle-module-basesystem,containers")
+
+	products := readProducts(t, "testdata/products-sle15.json")
+
+	prepareLogger()
+	if err := CheckRequestedModules(products, false); err != nil {
+		t.Fatalf("Unknown modules should only be a warning: %v", err)
+	}
+	if !strings.Contains(logged.String(), "'sle-module-containres' (did you mean 'sle-module-containers'?)") {
+		t.Fatalf("Expected a suggestion, got: %v", logged.String())
+	}
+
+	err := CheckRequestedModules(products, true)
+	if err == nil {
+		t.Fatal("Unknown modules should fail in strict mode")
+	}
+
+	expected := "Unknown modules requested: 'containers' (did you mean 'sle-module-containers'?), " +
+		"'sle-module-containres' (did you mean 'sle-module-containers'?)"
+	if err.Error() != expected {
+		t.Fatalf("Expected '%v', got '%v'", expected, err)
+	}
+}
+
+func TestCheckRequestedModulesKnown(t *testing.T) {
+	withDropIns(t, nil)
+	t.Setenv("ADDITIONAL_MODULES", "sle-module-containers,PackageHub")
+
+	products := readProducts(t, "testdata/products-sle15.json")
+	if err := CheckRequestedModules(products, true); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+}
@@ -0,0 +1,282 @@
+// Copyright (c) 2026 SUSE LLC. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containersuseconnect
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/SUSE/container-suseconnect/internal/regionsrv"
+)
+
+// lastOutputFileName is the name of the file in the runtime directory which
+// holds the last output of the zypper plugin.
+const lastOutputFileName = "zypp-plugin.repo"
+
+// lastOutputSource is the source of the repositories from the last output of
+// the zypper plugin.
+const lastOutputSource = "last plugin output"
+
+// AttributeChange is the change of an attribute of a repository.
+type AttributeChange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// PlannedRepository is a repository which is added, removed or changed. The
+// URLs of its attributes never include their query, since it holds the
+// authentication token of the repository.
+type PlannedRepository struct {
+	Alias      string                     `json:"alias"`
+	Source     string                     `json:"source,omitempty"`
+	Attributes map[string]string          `json:"attributes,omitempty"`
+	Changes    map[string]AttributeChange `json:"changes,omitempty"`
+}
+
+// RepositoryPlan holds the differences between the repositories currently
+// configured in the container and the ones the zypper plugin would give now.
+type RepositoryPlan struct {
+	Added   []PlannedRepository `json:"added"`
+	Removed []PlannedRepository `json:"removed"`
+	Changed []PlannedRepository `json:"changed"`
+}
+
+// repoSection is a section of a zypper repository file.
+type repoSection struct {
+	alias      string
+	attributes map[string]string
+	source     string
+}
+
+// parseRepoSections returns the sections of the given zypper repository
+// file. The query of the URLs is dropped, since it holds authentication
+// tokens which change on every refresh.
+func parseRepoSections(r io.Reader, source string) []repoSection {
+	var sections []repoSection
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			sections = append(sections, repoSection{
+				alias:      strings.TrimSpace(line[1 : len(line)-1]),
+				attributes: map[string]string{},
+				source:     source,
+			})
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || len(sections) == 0 {
+			continue
+		}
+
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if key == "baseurl" {
+			value = repositoryLocation(Repository{URL: value})
+		}
+		sections[len(sections)-1].attributes[key] = value
+	}
+
+	return sections
+}
+
+// currentRepositories returns the repositories of the container-suseconnect
+// service which are currently configured in the container, indexed by their
+// alias. The ones stored by zypper in its repository files are overridden by
+// the last output of the zypper plugin, if any.
+func currentRepositories() map[string]repoSection {
+	current := map[string]repoSection{}
+
+	paths, _ := filepath.Glob(filepath.Join(reposDir, "*.repo"))
+	for _, path := range paths {
+		if !serviceRepository(path) {
+			continue
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			log.Printf("Warning: skipping %v: %v", path, err)
+			continue
+		}
+
+		for _, section := range parseRepoSections(file, path) {
+			// zypper prefixes the aliases of the repositories of a service
+			// with the alias of the service.
+			section.alias = strings.TrimPrefix(section.alias, ServiceName+":")
+			delete(section.attributes, "service")
+			current[section.alias] = section
+		}
+		file.Close()
+	}
+
+	if data, err := regionsrv.ReadRuntimeFile(lastOutputFileName); err == nil {
+		for _, section := range parseRepoSections(bytes.NewReader(data), lastOutputSource) {
+			current[section.alias] = section
+		}
+	}
+
+	return current
+}
+
+// changedAttributes returns the attributes which differ between the current
+// and the planned section. Attributes which are only in the current section
+// are ignored when it comes from zypper, which stores some of its defaults.
+func changedAttributes(current, planned repoSection) map[string]AttributeChange {
+	changes := map[string]AttributeChange{}
+
+	for key, value := range planned.attributes {
+		if current.attributes[key] != value {
+			changes[key] = AttributeChange{From: current.attributes[key], To: value}
+		}
+	}
+
+	if current.source == lastOutputSource {
+		for key, value := range current.attributes {
+			if _, ok := planned.attributes[key]; !ok {
+				changes[key] = AttributeChange{From: value}
+			}
+		}
+	}
+
+	return changes
+}
+
+// PlanRepositories compares the repositories the zypper plugin would give for
+// the given products with the ones currently configured in the container,
+// which are the repositories of the service stored by zypper and the last
+// output of the zypper plugin.
+func PlanRepositories(products []Product) RepositoryPlan {
+	var buf bytes.Buffer
+	DumpProducts(&buf, products)
+
+	planned := map[string]repoSection{}
+	for _, section := range parseRepoSections(&buf, "") {
+		planned[section.alias] = section
+	}
+	current := currentRepositories()
+
+	plan := RepositoryPlan{
+		Added:   []PlannedRepository{},
+		Removed: []PlannedRepository{},
+		Changed: []PlannedRepository{},
+	}
+
+	for alias, section := range planned {
+		old, ok := current[alias]
+		if !ok {
+			plan.Added = append(plan.Added, PlannedRepository{Alias: alias, Attributes: section.attributes})
+			continue
+		}
+
+		if changes := changedAttributes(old, section); len(changes) > 0 {
+			plan.Changed = append(plan.Changed, PlannedRepository{Alias: alias, Source: old.source, Changes: changes})
+		}
+	}
+
+	for alias, section := range current {
+		if _, ok := planned[alias]; !ok {
+			plan.Removed = append(plan.Removed, PlannedRepository{
+				Alias:      alias,
+				Source:     section.source,
+				Attributes: section.attributes,
+			})
+		}
+	}
+
+	for _, repos := range [][]PlannedRepository{plan.Added, plan.Removed, plan.Changed} {
+		sort.Slice(repos, func(i, j int) bool { return repos[i].Alias < repos[j].Alias })
+	}
+
+	return plan
+}
+
+// sortedKeys returns the keys of the given map in lexical order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// WriteText prints the plan to the given writer in a human readable way.
+func (plan RepositoryPlan) WriteText(w io.Writer) {
+	if len(plan.Added)+len(plan.Removed)+len(plan.Changed) == 0 {
+		fmt.Fprintf(w, "No changes.\n")
+		return
+	}
+
+	for _, repo := range plan.Added {
+		fmt.Fprintf(w, "+ %v (%v)\n", repo.Alias, repo.Attributes["baseurl"])
+	}
+	for _, repo := range plan.Removed {
+		fmt.Fprintf(w, "- %v (from %v)\n", repo.Alias, repo.Source)
+	}
+	for _, repo := range plan.Changed {
+		fmt.Fprintf(w, "~ %v (from %v)\n", repo.Alias, repo.Source)
+		for _, key := range sortedKeys(repo.Changes) {
+			fmt.Fprintf(w, "    %v: '%v' -> '%v'\n", key, repo.Changes[key].From, repo.Changes[key].To)
+		}
+	}
+}
+
+// WriteJSON prints the plan to the given writer as JSON.
+func (plan RepositoryPlan) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(plan)
+}
+
+// SaveLastPluginOutput stores the repositories of the given output of the
+// zypper plugin into the runtime directory, so they can be compared by the
+// `plan` subcommand. The query of their URLs is dropped, so the stored file
+// never holds the authentication tokens of the repositories. It is removed by
+// the `scrub` subcommand.
+func SaveLastPluginOutput(output []byte) {
+	var buf bytes.Buffer
+	for _, section := range parseRepoSections(bytes.NewReader(output), lastOutputSource) {
+		fmt.Fprintf(&buf, "[%s]\n", section.alias)
+		for _, key := range sortedKeys(section.attributes) {
+			fmt.Fprintf(&buf, "%s=%s\n", key, section.attributes[key])
+		}
+		fmt.Fprintf(&buf, "\n")
+	}
+
+	if err := regionsrv.WriteRuntimeFile(lastOutputFileName, buf.Bytes()); err != nil {
+		log.Printf("Could not save the output of the zypper plugin: %v", err)
+	}
+}
+
+// RemoveLastPluginOutput removes the last output of the zypper plugin from the
+// runtime directory.
+func RemoveLastPluginOutput() error {
+	return regionsrv.RemoveRuntimeFile(lastOutputFileName)
+}
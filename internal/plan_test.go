@@ -0,0 +1,156 @@
+// Copyright (c) 2026 SUSE LLC. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containersuseconnect
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SUSE/container-suseconnect/internal/regionsrv"
+)
+
+// findPlanned returns the repository with the given alias from `repos`.
+func findPlanned(repos []PlannedRepository, alias string) *PlannedRepository {
+	for i := range repos {
+		if repos[i].Alias == alias {
+			return &repos[i]
+		}
+	}
+
+	return nil
+}
+
+func TestPlanRepositories(t *testing.T) {
+	withDropIns(t, nil)
+	t.Setenv("ADDITIONAL_MODULES", "")
+	t.Setenv(regionsrv.RuntimeDirEnv, t.TempDir())
+
+	old := reposDir
+	defer func() { reposDir = old }()
+
+	reposDir = t.TempDir()
+	writeScrubFiles(t, reposDir, map[string]string{
+		"service.repo": "[container-suseconnect-zypp:SLE-Product-SLES15-Pool]\n" +
+			"name=SLE-Product-SLES15-Pool for sle-15-x86_64\nenabled=1\nautorefresh=0\n" +
+			"baseurl=http://old.example.com/SLES15-Pool/?token\ngpgcheck=1\nservice=" + ServiceName + "\n\n" +
+			"[container-suseconnect-zypp:SLE-Module-Gone15-Pool]\nbaseurl=http://old.example.com/gone/\n" +
+			"service=" + ServiceName + "\n",
+		"local.repo": "[local]\nbaseurl=http://example.com/\n",
+	})
+
+	SaveLastPluginOutput([]byte("[SLE-Module-Basesystem15-Updates]\n" +
+		"name=SLE-Module-Basesystem15-Updates for sle-15-x86_64\n" +
+		"baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Updates/SLE-Module-Basesystem/15/x86_64/update/?other\n" +
+		"autorefresh=1\nenabled=0\npriority=90\n\n"))
+
+	products := readProducts(t, "testdata/products-sle15.json")
+	plan := PlanRepositories(products)
+
+	if findPlanned(plan.Added, "SLE-Product-SLES15-Updates") == nil {
+		t.Fatalf("Expected SLE-Product-SLES15-Updates to be added: %+v", plan.Added)
+	}
+	if findPlanned(plan.Added, "local") != nil || findPlanned(plan.Removed, "local") != nil {
+		t.Fatal("Repositories which are not part of the service should be ignored")
+	}
+
+	removed := findPlanned(plan.Removed, "SLE-Module-Gone15-Pool")
+	if removed == nil || removed.Source != filepath.Join(reposDir, "service.repo") {
+		t.Fatalf("Expected SLE-Module-Gone15-Pool to be removed: %+v", plan.Removed)
+	}
+
+	changed := findPlanned(plan.Changed, "SLE-Product-SLES15-Pool")
+	if changed == nil {
+		t.Fatalf("Expected SLE-Product-SLES15-Pool to be changed: %+v", plan.Changed)
+	}
+	expected := AttributeChange{
+		From: "http://old.example.com/SLES15-Pool/",
+		To:   "http://smt-ec2.susecloud.net/repo/SUSE/Products/SLE-Product-SLES/15/x86_64/product/",
+	}
+	if len(changed.Changes) != 1 || changed.Changes["baseurl"] != expected {
+		t.Fatalf("Expected only the location to change, got: %+v", changed.Changes)
+	}
+
+	changed = findPlanned(plan.Changed, "SLE-Module-Basesystem15-Updates")
+	if changed == nil || changed.Source != lastOutputSource {
+		t.Fatalf("Expected SLE-Module-Basesystem15-Updates to be changed: %+v", plan.Changed)
+	}
+	if len(changed.Changes) != 2 ||
+		changed.Changes["enabled"] != (AttributeChange{From: "0", To: "1"}) ||
+		changed.Changes["priority"] != (AttributeChange{From: "90"}) {
+		t.Fatalf("Unexpected changes: %+v", changed.Changes)
+	}
+
+	var buf bytes.Buffer
+	plan.WriteText(&buf)
+	if !strings.Contains(buf.String(), "~ SLE-Module-Basesystem15-Updates (from last plugin output)\n"+
+		"    enabled: '0' -> '1'\n    priority: '90' -> ''\n") {
+		t.Fatalf("Unexpected text output:\n%v", buf.String())
+	}
+	if strings.Contains(buf.String(), "credentials=") || strings.Contains(buf.String(), "?token") {
+		t.Fatalf("The output should not include the queries of the URLs:\n%v", buf.String())
+	}
+
+	buf.Reset()
+	if err := plan.WriteJSON(&buf); err != nil {
+		t.Fatalf("Could not write JSON: %v", err)
+	}
+	var decoded RepositoryPlan
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("Could not decode JSON: %v", err)
+	}
+	if len(decoded.Added) != len(plan.Added) || len(decoded.Removed) != 1 || len(decoded.Changed) != 2 {
+		t.Fatalf("Unexpected JSON output:\n%v", buf.String())
+	}
+}
+
+func TestPlanRepositoriesNoChanges(t *testing.T) {
+	withDropIns(t, nil)
+	t.Setenv("ADDITIONAL_MODULES", "")
+	t.Setenv(regionsrv.RuntimeDirEnv, t.TempDir())
+
+	old := reposDir
+	defer func() { reposDir = old }()
+	reposDir = t.TempDir()
+
+	products := readProducts(t, "testdata/products-sle15.json")
+
+	var output bytes.Buffer
+	DumpProducts(&output, products)
+	SaveLastPluginOutput(output.Bytes())
+
+	var buf bytes.Buffer
+	PlanRepositories(products).WriteText(&buf)
+	if buf.String() != "No changes.\n" {
+		t.Fatalf("Expected no changes, got:\n%v", buf.String())
+	}
+
+	saved, err := regionsrv.ReadRuntimeFile(lastOutputFileName)
+	if err != nil {
+		t.Fatalf("Could not read the last output: %v", err)
+	}
+	if !strings.Contains(output.String(), "credentials=") || strings.Contains(string(saved), "credentials=") {
+		t.Fatalf("The authentication tokens should not have been saved:\n%s", saved)
+	}
+
+	if err := RemoveLastPluginOutput(); err != nil {
+		t.Fatalf("Could not remove the last output: %v", err)
+	}
+	if _, err := regionsrv.ReadRuntimeFile(lastOutputFileName); err == nil {
+		t.Fatal("The last output should have been removed")
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright (c) 2026 SUSE LLC. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containersuseconnect
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"strings"
+)
+
+// PolicyEnv is the environment variable used to specify an additional policy
+// file.
+const PolicyEnv = "CONTAINER_SUSECONNECT_POLICY"
+
+var policyLocations = []string{
+	"/etc/container-suseconnect/policy.conf",
+	"/run/secrets/container-suseconnect-policy",
+}
+
+// policyRule allows or denies the products or repositories matching the glob
+// `pattern`.
+type policyRule struct {
+	key     string
+	kind    string
+	allow   bool
+	pattern string
+	source  string
+}
+
+func (rule policyRule) String() string {
+	return fmt.Sprintf("%s=%s from %s", rule.key, rule.pattern, rule.source)
+}
+
+// Policy restricts which products and repositories can be enabled, e.g. as
+// enforced by the platform team on the build hosts. Policy files contain
+// lines like:
+//
+//	deny-product=sle-module-legacy
+//	allow-repository=SLE-*
+//
+// Deny rules always win. If there are allow rules for products or for
+// repositories, only the matching ones are permitted.
+type Policy struct {
+	rules  []policyRule
+	source string
+	err    error
+}
+
+// activePolicy is the policy enforced when dumping repositories and listing
+// modules, as loaded by LoadPolicy.
+var activePolicy = &Policy{}
+
+func (p *Policy) separator() byte {
+	return '='
+}
+
+func (p *Policy) locations() []string {
+	return []string{p.source}
+}
+
+func (p *Policy) onLocationsNotFound() bool {
+	return true
+}
+
+func (p *Policy) setValues(key, value string) {
+	action, kind, _ := strings.Cut(key, "-")
+	if (action != "allow" && action != "deny") || (kind != "product" && kind != "repository") {
+		if p.err == nil {
+			p.err = loggedError(ConfigurationError, "Unknown policy rule '%v' in %v", key, p.source)
+		}
+		return
+	}
+
+	for _, pattern := range strings.Split(value, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+
+		if _, err := path.Match(pattern, ""); err != nil {
+			if p.err == nil {
+				p.err = loggedError(ConfigurationError, "Invalid pattern '%v' in %v: %v", pattern, p.source, err)
+			}
+			continue
+		}
+
+		p.rules = append(p.rules, policyRule{
+			key:     key,
+			kind:    kind,
+			allow:   action == "allow",
+			pattern: pattern,
+			source:  p.source,
+		})
+	}
+}
+
+func (p *Policy) afterParseCheck() error {
+	return p.err
+}
+
+// policyFiles returns the paths of the policy files, which are all enforced
+// together. The one given by PolicyEnv can only add further restrictions.
+func policyFiles() []string {
+	files := append([]string{}, policyLocations...)
+	if path := strings.TrimSpace(os.Getenv(PolicyEnv)); path != "" {
+		files = append(files, path)
+	}
+
+	return files
+}
+
+// LoadPolicy reads all the policy files and enforces them from now on. If any
+// of these files cannot be read, an error is returned so callers do not go
+// ahead without the policy.
+func LoadPolicy() error {
+	policy := &Policy{}
+
+	for _, file := range policyFiles() {
+		if _, err := os.Stat(file); os.IsNotExist(err) {
+			continue
+		}
+
+		policy.source = file
+		if err := ReadConfiguration(policy); err != nil {
+			return err
+		}
+	}
+
+	activePolicy = policy
+	return nil
+}
+
+// blockedBy returns a description of the rule blocking the given product or
+// repository name, or an empty string if it is permitted.
+func (p *Policy) blockedBy(kind, name string) string {
+	var allowRules []string
+	allowed := false
+
+	for _, rule := range p.rules {
+		if rule.kind != kind {
+			continue
+		}
+
+		matched, _ := path.Match(rule.pattern, name)
+		if !rule.allow && matched {
+			return rule.String()
+		}
+
+		if rule.allow {
+			allowRules = append(allowRules, rule.String())
+			allowed = allowed || matched
+		}
+	}
+
+	if len(allowRules) > 0 && !allowed {
+		return fmt.Sprintf("no match in %s", strings.Join(allowRules, ", "))
+	}
+
+	return ""
+}
+
+// productPermitted returns true if the policy permits the given product,
+// logging the rule blocking it otherwise.
+func (p *Policy) productPermitted(product Product) bool {
+	if rule := p.blockedBy("product", product.Identifier); rule != "" {
+		log.Printf("Policy: blocked product %s by rule '%s'", product.Identifier, rule)
+		return false
+	}
+
+	return true
+}
+
+// repositoryPermitted returns true if the policy permits the given
+// repository, logging the rule blocking it otherwise.
+func (p *Policy) repositoryPermitted(repo Repository) bool {
+	if rule := p.blockedBy("repository", repo.Name); rule != "" {
+		log.Printf("Policy: blocked repository %s by rule '%s'", repo.Name, rule)
+		return false
+	}
+
+	return true
+}
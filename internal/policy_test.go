@@ -0,0 +1,191 @@
+// Copyright (c) 2026 SUSE LLC. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containersuseconnect
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// withPolicy enforces the policy files with the given contents for the rest
+// of the test.
+func withPolicy(t *testing.T, contents ...string) {
+	dir := t.TempDir()
+
+	var locations []string
+	for i, content := range contents {
+		path := filepath.Join(dir, fmt.Sprintf("policy%d.conf", i))
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Could not write %v: %v", path, err)
+		}
+		locations = append(locations, path)
+	}
+
+	origLocations, origPolicy := policyLocations, activePolicy
+	t.Cleanup(func() {
+		policyLocations, activePolicy = origLocations, origPolicy
+	})
+	policyLocations = locations
+	t.Setenv(PolicyEnv, "")
+
+	if err := LoadPolicy(); err != nil {
+		t.Fatalf("Could not load the policy: %v", err)
+	}
+}
+
+func TestPolicyBlockedBy(t *testing.T) {
+	withPolicy(t, "allow-product=SLES, sle-module-*\ndeny-product=sle-module-legacy\n")
+
+	cases := []struct {
+		name, rule string
+	}{
+		{"SLES", ""},
+		{"sle-module-containers", ""},
+		{"sle-module-legacy", "deny-product=sle-module-legacy from " + policyLocations[0]},
+		{"PackageHub", "no match in allow-product=SLES from " + policyLocations[0] +
+			", allow-product=sle-module-* from " + policyLocations[0]},
+	}
+
+	for _, c := range cases {
+		if rule := activePolicy.blockedBy("product", c.name); rule != c.rule {
+			t.Fatalf("Expected '%v' to be blocked by '%v', got '%v'", c.name, c.rule, rule)
+		}
+	}
+
+	if rule := activePolicy.blockedBy("repository", "PackageHub"); rule != "" {
+		t.Fatalf("Product rules should not apply to repositories, got '%v'", rule)
+	}
+}
+
+func TestPolicyFilesAreCombined(t *testing.T) {
+	withPolicy(t, "deny-product=sle-module-legacy\n")
+
+	extra := filepath.Join(t.TempDir(), "extra.conf")
+	if err := os.WriteFile(extra, []byte("deny-product=PackageHub\n"), 0644); err != nil {
+		t.Fatalf("Could not write %v: %v", extra, err)
+	}
+	t.Setenv(PolicyEnv, extra)
+
+	if err := LoadPolicy(); err != nil {
+		t.Fatalf("Could not load the policy: %v", err)
+	}
+
+	for _, name := range []string{"sle-module-legacy", "PackageHub"} {
+		if activePolicy.blockedBy("product", name) == "" {
+			t.Fatalf("Expected '%v' to be blocked", name)
+		}
+	}
+}
+
+func TestPolicyInvalidRule(t *testing.T) {
+	withPolicy(t)
+
+	path := filepath.Join(t.TempDir(), "policy.conf")
+	if err := os.WriteFile(path, []byte("block-product=SLES\n"), 0644); err != nil {
+		t.Fatalf("Could not write %v: %v", path, err)
+	}
+	t.Setenv(PolicyEnv, path)
+
+	prepareLogger()
+	err := LoadPolicy()
+	if err == nil {
+		t.Fatal("Expected an error for an unknown rule")
+	}
+
+	expected := "Unknown policy rule 'block-product' in " + path
+	if err.Error() != expected {
+		t.Fatalf("Expected '%v', got '%v'", expected, err)
+	}
+	if len(activePolicy.rules) != 0 {
+		t.Fatal("A broken policy should not be enforced")
+	}
+}
+
+func TestDumpProductsWithPolicy(t *testing.T) {
+	withDropIns(t, nil)
+	t.Setenv("ADDITIONAL_MODULES", "sle-module-legacy,sle-module-web-scripting")
+	withPolicy(t, "deny-product=sle-module-server-applications\ndeny-repository=*-Debuginfo-*\n")
+
+	products := readProducts(t, "testdata/products-sle15.json")
+
+	prepareLogger()
+	var buf bytes.Buffer
+	DumpProducts(&buf, []Product{products[0]})
+
+	for _, name := range []string{"Debuginfo", "Server-Applications", "Legacy", "Web-Scripting"} {
+		if strings.Contains(buf.String(), name) {
+			t.Fatalf("Repositories with '%v' should have been blocked:\n%v", name, buf.String())
+		}
+	}
+	if !strings.Contains(buf.String(), "[SLE-Module-Basesystem15-Updates]") {
+		t.Fatalf("Basesystem should not have been blocked:\n%v", buf.String())
+	}
+
+	expected := "Policy: blocked product sle-module-server-applications by rule " +
+		"'deny-product=sle-module-server-applications from " + policyLocations[0] + "'"
+	if !strings.Contains(logged.String(), expected) {
+		t.Fatalf("Expected '%v' to be logged, got: %v", expected, logged.String())
+	}
+	if !strings.Contains(logged.String(), "Policy: blocked repository SLE-Product-SLES15-Debuginfo-Pool") {
+		t.Fatalf("Expected the blocked repositories to be logged, got: %v", logged.String())
+	}
+}
+
+func TestDumpProductsWithPolicyOnUnselectedProduct(t *testing.T) {
+	withDropIns(t, nil)
+	t.Setenv("ADDITIONAL_MODULES", "sle-module-development-tools")
+	withPolicy(t, "deny-product=sle-module-desktop-applications\n")
+
+	products := readProducts(t, "testdata/products-sle15.json")
+
+	prepareLogger()
+	var buf bytes.Buffer
+	DumpProducts(&buf, []Product{products[0]})
+
+	// The extensions of a blocked product are blocked too, even if the
+	// product itself would not have been selected.
+	if strings.Contains(buf.String(), "Development-Tools") {
+		t.Fatalf("The extensions of blocked products should have been blocked:\n%v", buf.String())
+	}
+	if strings.Contains(logged.String(), "Policy: blocked product") {
+		t.Fatalf("Products which are not selected should not be reported, got: %v", logged.String())
+	}
+}
+
+func TestListModulesWithPolicy(t *testing.T) {
+	withPolicy(t, "deny-product=sle-module-desktop-applications,sle-module-containers\n")
+
+	products := readProducts(t, "testdata/products-sle15.json")
+
+	prepareLogger()
+	var buf bytes.Buffer
+	ListModules(&buf, products)
+
+	for _, identifier := range []string{"sle-module-containers", "sle-module-desktop-applications", "sle-module-development-tools"} {
+		if strings.Contains(buf.String(), "Identifier: "+identifier+"\n") {
+			t.Fatalf("'%v' should have been blocked:\n%v", identifier, buf.String())
+		}
+	}
+	if !strings.Contains(buf.String(), "Identifier: sle-module-legacy\n") {
+		t.Fatalf("sle-module-legacy should have been listed:\n%v", buf.String())
+	}
+	if !strings.Contains(logged.String(), "Policy: blocked product sle-module-containers") {
+		t.Fatalf("Expected the blocked modules to be logged, got: %v", logged.String())
+	}
+}
@@ -15,14 +15,23 @@
 package containersuseconnect
 
 import (
-	"crypto/tls"
 	"encoding/json"
 	"io"
 	"log"
 	"net/http"
 	"net/url"
+	"os"
+	"strings"
 )
 
+// ProductsAPIEnv is the environment variable used to choose how the products
+// are requested from the registration server. With `subscriptions` the
+// registration codes of the system are requested first, and then the
+// products of each of them. With `system` the activated products are
+// requested with the system credentials only. The default, `auto`, uses the
+// system credentials on SCC and the subscriptions otherwise.
+const ProductsAPIEnv = "CONTAINER_SUSECONNECT_PRODUCTS_API"
+
 // Repository has all the information we need from repositories as given by the
 // registration server.
 type Repository struct {
@@ -31,6 +40,14 @@ type Repository struct {
 	URL         string `json:"url"`
 	Autorefresh bool   `json:"autorefresh"`
 	Enabled     bool   `json:"enabled"`
+
+	// Optional attributes, which are left to the defaults of zypper when
+	// not set.
+	Priority     *int   `json:"priority,omitempty"`
+	GPGCheck     *bool  `json:"gpgcheck,omitempty"`
+	KeepPackages *bool  `json:"keeppackages,omitempty"`
+	Type         string `json:"type,omitempty"`
+	Path         string `json:"path,omitempty"`
 }
 
 // Product has all the information we need from product as given by the registration
@@ -50,7 +67,8 @@ type Product struct {
 // Take the "Product" as returned from an RMT and adjust the repository URLs
 // to include a "credentials" parameter. This is somewhat specific to the RMT
 // instances available for Public Cloud on-demand instances, as they need to
-// authenticate to be able to access repositories.
+// authenticate to be able to access repositories. The zypper plugin points
+// this parameter to the credentials file generated by UseCredentialsFile.
 func fixRepoUrlsForRMT(p *Product) error {
 	for i := range p.Repositories {
 		repourl, err := url.Parse(p.Repositories[i].URL)
@@ -59,11 +77,14 @@ func fixRepoUrlsForRMT(p *Product) error {
 			return err
 		}
 
+		// Repositories which already reference a credentials file are left
+		// untouched, while other parameters are kept.
 		params := repourl.Query()
-		if params.Get("credentials") == "" {
-			params["credentials"] = []string{"SCCcredentials"}
+		if params.Has("credentials") {
+			continue
 		}
 
+		params.Set("credentials", "SCCcredentials")
 		repourl.RawQuery = params.Encode()
 		p.Repositories[i].URL = repourl.String()
 	}
@@ -81,6 +102,14 @@ func fixRepoUrlsForRMT(p *Product) error {
 // Parse the product as expected from the given reader. This function already
 // checks whether the given reader is valid or not.
 func parseProducts(reader io.Reader) ([]Product, error) {
+	return parseServerProducts(reader, ServerTypeUnknown)
+}
+
+// parseServerProducts parses the products given by a registration server of
+// the given type from the reader. The repositories of the servers whose type
+// needs it get a "credentials" parameter. If the type is unknown, it is
+// inferred from the format of the response.
+func parseServerProducts(reader io.Reader, serverType ServerType) ([]Product, error) {
 	var products []Product
 
 	data, err := io.ReadAll(reader)
@@ -94,6 +123,7 @@ func parseProducts(reader io.Reader) ([]Product, error) {
 	// a single element in it or a single product
 	// ("/connect/systems/products").  So we need to Unmarshall() slightly
 	// different for both cases.
+	fixURLs := serverType.needsCredentialsFile()
 	err = json.Unmarshal(data, &products)
 	if err != nil {
 		products = nil
@@ -103,8 +133,8 @@ func parseProducts(reader io.Reader) ([]Product, error) {
 		var product Product
 		err = json.Unmarshal(data, &product)
 		if err == nil {
-			fixRepoUrlsForRMT(&product)
 			products = append(products, product)
+			fixURLs = fixURLs || serverType == ServerTypeUnknown
 		}
 	}
 
@@ -113,6 +143,12 @@ func parseProducts(reader io.Reader) ([]Product, error) {
 			loggedError(RepositoryError, "Can't read product information: %v - %s", err.Error(), data)
 	}
 
+	if fixURLs {
+		for i := range products {
+			fixRepoUrlsForRMT(&products[i])
+		}
+	}
+
 	return products, nil
 }
 
@@ -153,14 +189,7 @@ func requestProductsFromRegCodeOrSystem(data SUSEConnectData, regCode string,
 		}
 	}
 
-	client := &http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: data.Insecure,
-			},
-			Proxy: http.ProxyFromEnvironment,
-		},
-	}
+	client := newHTTPClient(data)
 
 	resp, err := client.Do(req)
 	if err != nil {
@@ -180,13 +209,34 @@ func requestProductsFromRegCodeOrSystem(data SUSEConnectData, regCode string,
 		return products, loggedError(SubscriptionServerError, "Unexpected error while retrieving products with regCode %s: %s", regCode, resp.Status)
 	}
 
-	return parseProducts(resp.Body)
+	return parseServerProducts(resp.Body, data.ServerType)
+}
+
+// productsAPI returns how the products have to be requested from the
+// registration server given by `data`, either "system" or "subscriptions",
+// as chosen by the ProductsAPIEnv environment variable.
+func productsAPI(data SUSEConnectData) (string, error) {
+	switch api := strings.TrimSpace(os.Getenv(ProductsAPIEnv)); api {
+	case "", "auto":
+		if data.ServerType == ServerTypeSCC || isSCCHost(data.SccURL) {
+			return "system", nil
+		}
+		return "subscriptions", nil
+	case "system", "subscriptions":
+		return api, nil
+	default:
+		return "", loggedError(ConfigurationError, "Invalid value '%v' for %v", api, ProductsAPIEnv)
+	}
 }
 
 // RequestProducts fetches product information to the registration server. The
 // `data` and the `credentials` parameters are used in order to establish the
 // connection with the registration server. The `installed` parameter contains
-// the product to be requested.
+// the product to be requested. The products given by all the subscriptions are
+// merged together. Depending on ProductsAPIEnv, the activated products are
+// requested with the system credentials instead, so no registration code is
+// ever handled. When this was chosen automatically and it fails, the
+// subscriptions are used.
 func RequestProducts(data SUSEConnectData, credentials Credentials,
 	installed InstalledProduct,
 ) ([]Product, error) {
@@ -194,6 +244,27 @@ func RequestProducts(data SUSEConnectData, credentials Credentials,
 	var regCodes []string
 	var err error
 
+	// These servers do not provide the registration codes of the
+	// subscriptions.
+	if data.ServerType.usesSystemProducts() {
+		products, err = requestProductsFromRegCodeOrSystem(data, "", credentials, installed)
+		return MergeProducts(products), err
+	}
+
+	api, err := productsAPI(data)
+	if err != nil {
+		return products, err
+	}
+
+	if api == "system" {
+		products, err = requestProductsFromRegCodeOrSystem(data, "", credentials, installed)
+		if err == nil || strings.TrimSpace(os.Getenv(ProductsAPIEnv)) == "system" {
+			return MergeProducts(products), err
+		}
+
+		log.Printf("Could not retrieve the products with the system credentials, using the subscriptions: %v", err)
+	}
+
 	regCodes, err = requestRegcodes(data, credentials)
 	if err != nil {
 		return products, err
@@ -213,5 +284,6 @@ func RequestProducts(data SUSEConnectData, credentials Credentials,
 		err = nil
 	}
 
-	return products, err
+	// Several subscriptions may give the same products.
+	return MergeProducts(products), err
 }
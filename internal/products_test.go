@@ -231,3 +231,110 @@ func TestValidRequestForProductUsingRMT(t *testing.T) {
 
 	productHelperSLE15RMT(t, products[0])
 }
+
+func TestFixRepoUrlsForRMT(t *testing.T) {
+	product := Product{
+		Repositories: []Repository{
+			{URL: "https://rmt.example.com/repo/update"},
+			{URL: "https://rmt.example.com/repo/update?arch=x86_64"},
+			{URL: "https://rmt.example.com/repo/update?credentials=Other"},
+		},
+		Extensions: []Product{{Repositories: []Repository{{URL: "https://rmt.example.com/repo/module"}}}},
+	}
+
+	if err := fixRepoUrlsForRMT(&product); err != nil {
+		t.Fatalf("It should've run just fine: %v", err)
+	}
+
+	expected := []string{
+		"https://rmt.example.com/repo/update?credentials=SCCcredentials",
+		"https://rmt.example.com/repo/update?arch=x86_64&credentials=SCCcredentials",
+		"https://rmt.example.com/repo/update?credentials=Other",
+	}
+	for i, url := range expected {
+		if product.Repositories[i].URL != url {
+			t.Fatalf("Expected '%v', got '%v'", url, product.Repositories[i].URL)
+		}
+	}
+	if url := product.Extensions[0].Repositories[0].URL; url != "https://rmt.example.com/repo/module?credentials=SCCcredentials" {
+		t.Fatalf("Unexpected URL of the extension: %v", url)
+	}
+}
+
+func TestProductsAPI(t *testing.T) {
+	cases := []struct {
+		env, url, api string
+	}{
+		{"", "https://scc.suse.com", "system"},
+		{"auto", "https://scc.suse.com/", "system"},
+		{"", "https://rmt.example.com", "subscriptions"},
+		{"subscriptions", "https://scc.suse.com", "subscriptions"},
+		{"system", "https://rmt.example.com", "system"},
+	}
+
+	for _, c := range cases {
+		t.Setenv(ProductsAPIEnv, c.env)
+		api, err := productsAPI(SUSEConnectData{SccURL: c.url})
+		if err != nil || api != c.api {
+			t.Fatalf("Expected '%v' for '%v' on %v, got '%v': %v", c.api, c.env, c.url, api, err)
+		}
+	}
+
+	t.Setenv(ProductsAPIEnv, "regcode")
+	prepareLogger()
+	if _, err := productsAPI(SUSEConnectData{SccURL: sccURLStr}); err == nil {
+		t.Fatal("Expected an error for an invalid value")
+	}
+}
+
+func TestValidRequestForProductUsingSystemCredentials(t *testing.T) {
+	t.Setenv(ProductsAPIEnv, "system")
+
+	// We setup a fake http server that mocks a registration server.
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/connect/systems/products" {
+			t.Errorf("Unexpected request to %v", r.URL.Path)
+			http.Error(w, "", http.StatusNotFound)
+			return
+		}
+
+		if user, pass, ok := r.BasicAuth(); !ok || user != "SCC_user" || pass != "secret" {
+			t.Errorf("Expected the system credentials, got '%v:%v'", user, pass)
+		}
+		if r.URL.Query().Get("identifier") != "SLES" {
+			t.Errorf("Unexpected query: %v", r.URL.RawQuery)
+		}
+
+		fmt.Fprint(w, `{"identifier": "SLES", "version": "15.5", "arch": "x86_64",
+			"product_type": "base", "repositories": [
+			{"name": "SLE-Product-SLES15-SP5-Pool",
+			 "url": "https://updates.suse.com/SUSE/Products/SLE-Product-SLES/15-SP5/x86_64/product?exp_token"},
+			{"name": "SLE-Product-SLES15-SP5-Updates",
+			 "url": "https://rmt.example.com/repo/SUSE/Updates/SLE-Product-SLES/15-SP5/x86_64/update"}]}`)
+	}))
+	defer ts.Close()
+
+	// The test server stands in for SCC, whose type is known from its URL.
+	cr := Credentials{Username: "SCC_user", Password: "secret"}
+	ip := InstalledProduct{Identifier: "SLES", Version: "15.5", Arch: "x86_64"}
+	data := SUSEConnectData{SccURL: ts.URL, Insecure: true, ServerType: ServerTypeSCC}
+
+	products, err := RequestProducts(data, cr, ip)
+	if err != nil {
+		t.Fatalf("It should've run just fine: %v", err)
+	}
+
+	if len(products) != 1 || len(products[0].Repositories) != 2 {
+		t.Fatalf("Unexpected products: %+v", products)
+	}
+
+	expectedURL := "https://updates.suse.com/SUSE/Products/SLE-Product-SLES/15-SP5/x86_64/product?exp_token"
+	if products[0].Repositories[0].URL != expectedURL {
+		t.Fatalf("The token of the repository should be kept: %s", products[0].Repositories[0].URL)
+	}
+
+	expectedURL = "https://rmt.example.com/repo/SUSE/Updates/SLE-Product-SLES/15-SP5/x86_64/update"
+	if products[0].Repositories[1].URL != expectedURL {
+		t.Fatalf("The repositories from SCC should not reference a credentials file: %s", products[0].Repositories[1].URL)
+	}
+}
@@ -17,8 +17,10 @@ package regionsrv
 import (
 	"crypto/sha256"
 	"io"
+	"log"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 )
 
@@ -55,30 +57,38 @@ func updateNeeded(contents string) bool {
 // saveCAFile implements `SaveCAFile` by assuming a `commander` type will be
 // given.
 func saveCAFile(cmd commander, contents string) error {
+	// Concurrent zypper processes may try to save the CA at the same time.
+	unlock, err := lockDir(filepath.Dir(caFilePath))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	if !updateNeeded(contents) {
 		return nil
 	}
 
-	// Nuke everything before populating things back again.
 	os.Remove(oldHashFilePath)
-	os.Remove(hashFilePath)
-	os.Remove(caFilePath)
 
-	// Save the file
-	err := os.WriteFile(caFilePath, []byte(contents), 0o644)
+	// Save the file. It is replaced atomically, so the trust store never sees
+	// a missing or partially written CA.
+	err = writeFileAtomic(caFilePath, []byte(contents), 0o644)
 	if err != nil {
 		return err
 	}
 
-	// Execute `update-ca-certificates` now.
-	if err = cmd.Run(); err != nil {
-		return err
+	// Execute `update-ca-certificates` now, unless the file is not part of
+	// the system trust store.
+	if updateTrustStore {
+		if err = cmd.Run(); err != nil {
+			return err
+		}
 	}
 
 	// Save the new checksum
 	hash := sha256.New()
 	io.WriteString(hash, contents)
-	os.WriteFile(hashFilePath, hash.Sum(nil), 0o644)
+	writeFileAtomic(hashFilePath, hash.Sum(nil), 0o644)
 
 	return nil
 }
@@ -87,6 +97,11 @@ func saveCAFile(cmd commander, contents string) error {
 // already there. This function will call `update-ca-certificates` whenever the
 // CA file has been updated.
 func SaveCAFile(contents string) error {
+	if caFilePath == "" {
+		log.Printf("Not installing the CA of the update server, the trust store is not writable")
+		return nil
+	}
+
 	cmd := exec.Command("update-ca-certificates")
 	return saveCAFile(cmd, contents)
 }
@@ -0,0 +1,333 @@
+// Copyright (c) 2026 SUSE LLC. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package regionsrv
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// RuntimeDirEnv is the environment variable used to specify a custom private
+// runtime directory.
+const RuntimeDirEnv = "CONTAINER_SUSECONNECT_RUNTIME_DIR"
+
+// CacheTTLEnv is the environment variable used to specify for how long the
+// configuration from the containerbuild-regionsrv server is cached (e.g.
+// `30s`). A value of `0` disables the cache.
+const CacheTTLEnv = "CONTAINER_BUILD_CACHE_TTL"
+
+var (
+	defaultRuntimeDir = "/run/container-suseconnect"
+	defaultCacheTTL   = time.Minute
+	cacheFileName     = "regionsrv.cache"
+
+	// cacheKeyDir holds the key of the cache. It is a tmpfs in containers,
+	// so it is never committed into an image layer.
+	cacheKeyDir = "/dev/shm"
+)
+
+// cacheKeySize is the size of the key of the cache, for AES-256.
+const cacheKeySize = 32
+
+// configCache is the on-disk representation of a cached ContainerBuildConfig.
+// The configuration itself is encrypted with a random key which is kept apart
+// from the cache in cacheKeyDir, so a cache file which leaks into an image
+// layer (e.g. when the runtime directory is not a tmpfs) cannot be decrypted.
+// This does not protect the configuration from processes of the same user,
+// which can read the key as well.
+type configCache struct {
+	Timestamp time.Time `json:"timestamp"`
+	Hash      string    `json:"hash"`
+	Nonce     []byte    `json:"nonce"`
+	Data      []byte    `json:"data"`
+}
+
+// runtimeDir returns the private runtime directory of this application.
+func runtimeDir() string {
+	if dir := strings.TrimSpace(os.Getenv(RuntimeDirEnv)); dir != "" {
+		return dir
+	}
+
+	return defaultRuntimeDir
+}
+
+// cacheTTL returns for how long a cached configuration is considered fresh.
+func cacheTTL() time.Duration {
+	if runtimeDir() == "" {
+		return 0
+	}
+
+	value := strings.TrimSpace(os.Getenv(CacheTTLEnv))
+	if value == "" {
+		return defaultCacheTTL
+	}
+
+	ttl, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Warning: invalid value '%v' for %v, using %v", value, CacheTTLEnv, defaultCacheTTL)
+		return defaultCacheTTL
+	}
+
+	return ttl
+}
+
+// cachePath returns the path to the cache file.
+func cachePath() string {
+	return filepath.Join(runtimeDir(), cacheFileName)
+}
+
+// cacheKeyPath returns the path to the key of the cache for the current user.
+func cacheKeyPath() string {
+	return filepath.Join(cacheKeyDir, fmt.Sprintf("container-suseconnect-%d.key", os.Geteuid()))
+}
+
+// readCacheKey returns the key of the cache stored at the given path. Since
+// cacheKeyDir is usually writable by everyone, the key is only used if it is
+// a private file of the current user.
+func readCacheKey(path string) ([]byte, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !info.Mode().IsRegular() || info.Mode().Perm() != 0o600 || !ok || int(stat.Uid) != os.Geteuid() {
+		return nil, fmt.Errorf("refusing to use %v as the key of the cache", path)
+	}
+
+	key, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != cacheKeySize {
+		return nil, fmt.Errorf("invalid key of the cache in %v", path)
+	}
+
+	return key, nil
+}
+
+// cacheKey returns the key of the cache, which is created the first time.
+func cacheKey() ([]byte, error) {
+	path := cacheKeyPath()
+
+	key, err := readCacheKey(path)
+	if !os.IsNotExist(err) {
+		return key, err
+	}
+
+	key = make([]byte, cacheKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if os.IsExist(err) {
+		// Created by a concurrent process in the meantime.
+		return readCacheKey(path)
+	} else if err != nil {
+		return nil, err
+	}
+
+	if _, err := file.Write(key); err != nil {
+		file.Close()
+		os.Remove(path)
+		return nil, err
+	}
+	if err := file.Close(); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// cacheCipher returns the AEAD used to encrypt the cached configuration.
+func cacheCipher() (cipher.AEAD, error) {
+	key, err := cacheKey()
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// configHash returns a checksum of the given configuration, which is used to
+// detect changes on the server's response.
+func configHash(cfg *ContainerBuildConfig) string {
+	data, _ := json.Marshal(cfg)
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// readCache returns the contents of the cache file, without looking at its
+// freshness.
+func readCache() (*configCache, error) {
+	data, err := os.ReadFile(cachePath())
+	if err != nil {
+		return nil, err
+	}
+
+	cache := &configCache{}
+	if err := json.Unmarshal(data, cache); err != nil {
+		return nil, err
+	}
+
+	return cache, nil
+}
+
+// decrypt returns the configuration stored in the given cache.
+func (cache *configCache) decrypt() (*ContainerBuildConfig, error) {
+	aead, err := cacheCipher()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := aead.Open(nil, cache.Nonce, cache.Data, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &ContainerBuildConfig{}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, err
+	}
+
+	if configHash(cfg) != cache.Hash {
+		return nil, errors.New("checksum mismatch on cached configuration")
+	}
+
+	return cfg, nil
+}
+
+// writeCache stores the given configuration into the cache file.
+func writeCache(cfg *ContainerBuildConfig) error {
+	aead, err := cacheCipher()
+	if err != nil {
+		return err
+	}
+
+	plain, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+
+	cache := &configCache{
+		Timestamp: time.Now(),
+		Hash:      configHash(cfg),
+		Nonce:     make([]byte, aead.NonceSize()),
+	}
+	if _, err := rand.Read(cache.Nonce); err != nil {
+		return err
+	}
+	cache.Data = aead.Seal(nil, cache.Nonce, plain, nil)
+
+	data, err := json.Marshal(cache)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(runtimeDir(), 0o700); err != nil {
+		return err
+	}
+
+	// Concurrent readers never see a partially written cache.
+	return writeFileAtomic(cachePath(), data, 0o600)
+}
+
+// invalidateCache removes the cached configuration, if any.
+func invalidateCache() {
+	if err := os.Remove(cachePath()); err != nil && !os.IsNotExist(err) {
+		log.Printf("Could not remove cached configuration: %v", err)
+	}
+}
+
+// freshCachedConfig returns the cached configuration if caching is enabled
+// and the cache is still fresh, and nil otherwise.
+func freshCachedConfig() *ContainerBuildConfig {
+	ttl := cacheTTL()
+	if ttl <= 0 {
+		return nil
+	}
+
+	cache, err := readCache()
+	if err != nil || time.Since(cache.Timestamp) > ttl {
+		return nil
+	}
+
+	cfg, err := cache.decrypt()
+	if err != nil {
+		log.Printf("Ignoring cached configuration: %v", err)
+		return nil
+	}
+
+	return cfg
+}
+
+// CachedConfigAvailable returns true if there is a fresh cached configuration
+// from the containerbuild-regionsrv server, so there is no need to contact the
+// server.
+func CachedConfigAvailable() bool {
+	return freshCachedConfig() != nil
+}
+
+// ReadCachedConfig behaves like ReadConfigFromServer, but it caches the
+// configuration in the private runtime directory for the time given by
+// CacheTTLEnv. This way zypper calling the URL resolver for each repository
+// only contacts the server once. The returned boolean is true if the
+// configuration differs from the one that was cached before, which means that
+// callers have to apply it again to the system (e.g. the CA file).
+func ReadCachedConfig() (*ContainerBuildConfig, bool, error) {
+	if cfg := freshCachedConfig(); cfg != nil {
+		log.Printf("Using cached configuration from containerbuild-regionsrv")
+		return cfg, false, nil
+	}
+
+	cfg, err := ReadConfigFromServer()
+	if err != nil {
+		return nil, false, err
+	}
+
+	if cacheTTL() <= 0 {
+		return cfg, true, nil
+	}
+
+	changed := true
+	if cache, err := readCache(); err == nil && cache.Hash == configHash(cfg) {
+		changed = false
+	}
+
+	if err := writeCache(cfg); err != nil {
+		log.Printf("Could not cache configuration: %v", err)
+	}
+
+	return cfg, changed, nil
+}
@@ -0,0 +1,188 @@
+// Copyright (c) 2026 SUSE LLC. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package regionsrv
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// beforeCacheTest points the cache into a temporary directory.
+func beforeCacheTest(t *testing.T) {
+	old := cacheKeyDir
+	t.Cleanup(func() { cacheKeyDir = old })
+
+	cacheKeyDir = t.TempDir()
+	t.Setenv(RuntimeDirEnv, filepath.Join(t.TempDir(), "runtime"))
+	t.Setenv(CacheTTLEnv, "")
+}
+
+// startTestServer runs a testServer with the given response until the test
+// finishes.
+func startTestServer(t *testing.T, response ContainerBuildConfig) *testServer {
+	ts := &testServer{
+		bootstrapped: make(chan bool, 1),
+		response:     response,
+	}
+	t.Cleanup(func() { ts.close() })
+
+	go ts.run()
+	<-ts.bootstrapped
+
+	return ts
+}
+
+// Tests start here
+
+func TestReadCachedConfig(t *testing.T) {
+	beforeCacheTest(t)
+
+	withSuppressedLog(func() {
+		response := ContainerBuildConfig{
+			InstanceData: "instance data",
+			Username:     "banjo",
+			Password:     "kazooie",
+		}
+		ts := startTestServer(t, response)
+
+		cfg, changed, err := ReadCachedConfig()
+		if err != nil {
+			t.Fatalf("Expected error to be nil: %v", err)
+		}
+		if !changed {
+			t.Fatal("The first read should be considered a change")
+		}
+		if cfg.Password != "kazooie" {
+			t.Fatalf("Unexpected password '%v'", cfg.Password)
+		}
+
+		// The server is gone now, but the cache is still fresh.
+		ts.close()
+
+		if !CachedConfigAvailable() {
+			t.Fatal("Expected a cached configuration to be available")
+		}
+
+		cfg, changed, err = ReadCachedConfig()
+		if err != nil {
+			t.Fatalf("Expected error to be nil: %v", err)
+		}
+		if changed {
+			t.Fatal("A cached configuration should not be considered a change")
+		}
+		if cfg.InstanceData != "instance data" {
+			t.Fatalf("Unexpected instance data '%v'", cfg.InstanceData)
+		}
+	})
+}
+
+func TestCachedConfigIsEncrypted(t *testing.T) {
+	beforeCacheTest(t)
+
+	withSuppressedLog(func() {
+		if err := writeCache(&ContainerBuildConfig{Username: "banjo", Password: "kazooie"}); err != nil {
+			t.Fatalf("Expected error to be nil: %v", err)
+		}
+
+		data, err := os.ReadFile(cachePath())
+		if err != nil {
+			t.Fatalf("Expected error to be nil: %v", err)
+		}
+		if strings.Contains(string(data), "kazooie") {
+			t.Fatal("The password should not be stored in plain text")
+		}
+
+		info, err := os.Stat(runtimeDir())
+		if err != nil {
+			t.Fatalf("Expected error to be nil: %v", err)
+		}
+		if info.Mode().Perm() != 0o700 {
+			t.Fatalf("Expected a private runtime directory, got %v", info.Mode().Perm())
+		}
+
+		info, err = os.Stat(cacheKeyPath())
+		if err != nil {
+			t.Fatalf("Expected error to be nil: %v", err)
+		}
+		if info.Mode().Perm() != 0o600 {
+			t.Fatalf("Expected a private key, got %v", info.Mode().Perm())
+		}
+
+		// The cache cannot be decrypted without its key.
+		cacheKeyDir = t.TempDir()
+		if CachedConfigAvailable() {
+			t.Fatal("The cache should not be readable with another key")
+		}
+
+		// Keys which may have been planted by other users are refused.
+		cacheKeyDir = t.TempDir()
+		if err := os.WriteFile(cacheKeyPath(), make([]byte, cacheKeySize), 0o644); err != nil {
+			t.Fatalf("Expected error to be nil: %v", err)
+		}
+		if err := writeCache(&ContainerBuildConfig{Username: "banjo"}); err == nil {
+			t.Fatal("A key readable by others should be refused")
+		}
+	})
+}
+
+func TestReadCachedConfigExpired(t *testing.T) {
+	beforeCacheTest(t)
+
+	withSuppressedLog(func() {
+		response := ContainerBuildConfig{InstanceData: "instance data"}
+		startTestServer(t, response)
+
+		t.Setenv(CacheTTLEnv, "1ns")
+		if _, _, err := ReadCachedConfig(); err != nil {
+			t.Fatalf("Expected error to be nil: %v", err)
+		}
+
+		if CachedConfigAvailable() {
+			t.Fatal("The cache should have expired already")
+		}
+
+		// Same response from the server, so no change is reported.
+		_, changed, err := ReadCachedConfig()
+		if err != nil {
+			t.Fatalf("Expected error to be nil: %v", err)
+		}
+		if changed {
+			t.Fatal("The server response did not change")
+		}
+	})
+}
+
+func TestReadCachedConfigDisabled(t *testing.T) {
+	beforeCacheTest(t)
+	t.Setenv(CacheTTLEnv, "0")
+
+	withSuppressedLog(func() {
+		startTestServer(t, ContainerBuildConfig{InstanceData: "instance data"})
+
+		_, changed, err := ReadCachedConfig()
+		if err != nil {
+			t.Fatalf("Expected error to be nil: %v", err)
+		}
+		if !changed {
+			t.Fatal("Without a cache every read should be considered a change")
+		}
+
+		if _, err := os.Stat(cachePath()); !os.IsNotExist(err) {
+			t.Fatalf("No cache file should have been written: %v", err)
+		}
+	})
+}
@@ -18,42 +18,83 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 )
 
 var hostsFile = "/etc/hosts"
 
-// UpdateHostsFile updates the hosts file with the given hostname and IP.
+// SkipHostsFileEnv is the environment variable which, when set to true,
+// prevents any modification of the hosts file.
+const SkipHostsFileEnv = "CONTAINER_SUSECONNECT_SKIP_HOSTS_FILE"
+
+// hostsMarker is appended to each entry written by UpdateHostsFile, so they
+// can be told apart from the other entries when scrubbing the system.
+const hostsMarker = "# container-suseconnect"
+
+// hostsFileSkipped returns true if the user opted out of modifying the hosts
+// file.
+func hostsFileSkipped() bool {
+	skip, err := strconv.ParseBool(os.Getenv(SkipHostsFileEnv))
+	return err == nil && skip
+}
+
+// UpdateHostsFile updates the hosts file with the given hostname and IP. The
+// hosts file is left untouched if `CONTAINER_SUSECONNECT_SKIP_HOSTS_FILE` is
+// set to true.
 func UpdateHostsFile(hostname string, ip string) error {
+	if hostsFileSkipped() {
+		log.Printf("Not adding %s to %s as requested by %s", hostname, hostsFile, SkipHostsFileEnv)
+		return nil
+	}
+	if hostsFile == "" {
+		log.Printf("Not adding %s to the hosts file, it is not writable", hostname)
+		return nil
+	}
+
+	// Concurrent zypper processes may update the hosts file at the same time.
+	unlock, err := lockDir(filepath.Dir(hostsFile))
+	if err != nil {
+		return fmt.Errorf("can't lock %s file: %v", hostsFile, err.Error())
+	}
+	defer unlock()
+
 	content, err := os.ReadFile(hostsFile)
 	if err != nil {
 		return fmt.Errorf("can't read %s file: %v", hostsFile, err.Error())
 	}
 
-	lines := strings.Split(string(content), "\n")
-	newcontent := ""
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
 	hostChecked := false
+	updated := false
 	shorthost := strings.Split(hostname, ".")[0]
+	entry := fmt.Sprintf("%s %s %s %s", ip, hostname, shorthost, hostsMarker)
 
-	for _, line := range lines {
+	for i, line := range lines {
 		fields := strings.Fields(line)
 		if len(fields) >= 2 && fields[1] == hostname {
 			if fields[0] != ip {
 				log.Printf("updating hosts entry for %s", hostname)
-				line = fmt.Sprintf("%s %s %s\n", ip, hostname, shorthost)
+				lines[i] = entry
+				updated = true
 			}
 
 			hostChecked = true
 		}
+	}
 
-		newcontent += line + "\n"
+	// Nothing to do if the entry is already there.
+	if hostChecked && !updated {
+		return nil
 	}
 
 	if !hostChecked {
-		newcontent += fmt.Sprintf("%s %s %s\n", ip, hostname, shorthost)
+		lines = append(lines, entry)
 	}
 
-	err = os.WriteFile(hostsFile, []byte(newcontent), 0o644)
+	newcontent := strings.Join(lines, "\n") + "\n"
+	err = writeFileAtomic(hostsFile, []byte(newcontent), 0o644)
 	if err != nil {
 		return fmt.Errorf("can't write %s file: %v", hostsFile, err.Error())
 	}
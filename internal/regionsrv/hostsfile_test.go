@@ -123,3 +123,34 @@ func TestUpdateHostsFileUpdateExistingEntry(t *testing.T) {
 		t.Fatalf("%v\nshould contain\n%v", string(after), expected)
 	}
 }
+
+func TestUpdateHostsFileSkipped(t *testing.T) {
+	hostsFile = copyHostFileToTemp(0o644)
+	if hostsFile == "" {
+		t.Fatalf("Failed to initialize hosts file")
+	}
+
+	defer os.Remove(hostsFile)
+
+	before, err := os.ReadFile(hostsFile)
+	if err != nil {
+		t.Fatalf("os.ReadFile failed with: %v", err)
+	}
+
+	t.Setenv(SkipHostsFileEnv, "true")
+
+	withSuppressedLog(func() {
+		if err := UpdateHostsFile("test-hostname", "1.1.1.1"); err != nil {
+			t.Fatalf("Expected a nil error, got: %v", err)
+		}
+	})
+
+	after, err := os.ReadFile(hostsFile)
+	if err != nil {
+		t.Fatalf("Expected a nil error, got: %v", err)
+	}
+
+	if string(after) != string(before) {
+		t.Fatalf("The hosts file should not have been modified:\n%v", string(after))
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2026 SUSE LLC. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package regionsrv
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// lockDir takes an exclusive advisory lock on the given directory, blocking
+// until it is available. Locking the directory instead of the files inside of
+// it allows replacing these files, and it also works for concurrent processes
+// which do not share the runtime directory (e.g. parallel builds sharing the
+// root filesystem). It returns a function which releases the lock.
+func lockDir(dir string) (func(), error) {
+	file, err := os.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return func() {
+		syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+		file.Close()
+	}, nil
+}
+
+// writeFileAtomic writes the given data into a temporary file which then
+// replaces the file at the given path, so readers never see a partially
+// written file. Files which cannot be replaced (e.g. /etc/hosts bind-mounted
+// by the container runtime) are written in place instead, so callers still
+// have to hold the lock on the directory.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	if info, err := os.Stat(path); err == nil {
+		// Respect the permissions of the file being replaced.
+		if !writable(path) {
+			return &os.PathError{Op: "open", Path: path, Err: syscall.EACCES}
+		}
+		perm = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		// The directory is not writable, but the file itself might be.
+		return os.WriteFile(path, data, perm)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	err = os.Rename(tmp.Name(), path)
+	if errors.Is(err, syscall.EBUSY) || errors.Is(err, syscall.EXDEV) {
+		return os.WriteFile(path, data, perm)
+	}
+
+	return err
+}
@@ -0,0 +1,166 @@
+// Copyright (c) 2026 SUSE LLC. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package regionsrv
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// checkingCommand implements the commander interface by checking that the CA
+// file is complete whenever the trust store would be updated.
+type checkingCommand struct {
+	valid map[string]bool
+}
+
+func (c checkingCommand) Run() error {
+	data, err := os.ReadFile(caFilePath)
+	if err != nil {
+		return err
+	}
+	if !c.valid[string(data)] {
+		return fmt.Errorf("corrupted CA file: '%s'", data)
+	}
+	return nil
+}
+
+// Tests start here
+
+func TestUpdateHostsFileConcurrent(t *testing.T) {
+	restoreSideEffects(t)
+
+	hostsFile = filepath.Join(t.TempDir(), "hosts")
+	if err := os.WriteFile(hostsFile, []byte("127.0.0.1 localhost\n"), 0o644); err != nil {
+		t.Fatalf("Could not write hosts file: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 50)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- UpdateHostsFile(fmt.Sprintf("smt%d.test.lan", i%10), fmt.Sprintf("1.1.1.%d", i))
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Expected a nil error, got: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(hostsFile)
+	if err != nil {
+		t.Fatalf("Expected a nil error, got: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != 11 || lines[0] != "127.0.0.1 localhost" {
+		t.Fatalf("Expected the original entry plus ten new ones, got:\n%s", data)
+	}
+	for i := 0; i < 10; i++ {
+		if strings.Count(string(data), fmt.Sprintf(" smt%d.test.lan ", i)) != 1 {
+			t.Fatalf("Expected a single entry for smt%d.test.lan, got:\n%s", i, data)
+		}
+	}
+}
+
+func TestSaveCAFileConcurrent(t *testing.T) {
+	restoreSideEffects(t)
+
+	dir := t.TempDir()
+	caFilePath = filepath.Join(dir, "ca.pem")
+	hashFilePath = filepath.Join(dir, "ca.sha256")
+	oldHashFilePath = filepath.Join(dir, "ca.md5")
+
+	contents := []string{strings.Repeat("a", 1<<16), strings.Repeat("b", 1<<16)}
+	cmd := checkingCommand{valid: map[string]bool{contents[0]: true, contents[1]: true}}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 50)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- saveCAFile(cmd, contents[i%2])
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Expected a nil error, got: %v", err)
+		}
+	}
+
+	ca, err := os.ReadFile(caFilePath)
+	if err != nil {
+		t.Fatalf("Expected a nil error, got: %v", err)
+	}
+	hash, err := os.ReadFile(hashFilePath)
+	if err != nil {
+		t.Fatalf("Expected a nil error, got: %v", err)
+	}
+
+	sum := sha256.Sum256(ca)
+	if string(hash) != string(sum[:]) {
+		t.Fatal("The checksum does not match the CA file")
+	}
+}
+
+func TestWriteFileAtomicInPlace(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root can write into any directory")
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "hosts")
+	if err := os.WriteFile(path, []byte("old"), 0o644); err != nil {
+		t.Fatalf("Could not write file: %v", err)
+	}
+
+	// The file can still be written in place if the directory is read-only.
+	if err := os.Chmod(dir, 0o500); err != nil {
+		t.Fatalf("Could not change permissions: %v", err)
+	}
+	defer os.Chmod(dir, 0o700)
+
+	if err := writeFileAtomic(path, []byte("new"), 0o644); err != nil {
+		t.Fatalf("Expected a nil error, got: %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if string(data) != "new" {
+		t.Fatalf("Expected 'new', got '%s'", data)
+	}
+
+	if err := os.Chmod(path, 0o400); err != nil {
+		t.Fatalf("Could not change permissions: %v", err)
+	}
+	err := writeFileAtomic(path, []byte("newer"), 0o644)
+	if !errors.Is(err, os.ErrPermission) {
+		t.Fatalf("Expected a permission error, got: %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2026 SUSE LLC. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package regionsrv
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// errNoRuntimeDir is returned when there is no usable runtime directory, e.g.
+// on read-only containers.
+var errNoRuntimeDir = errors.New("no writable runtime directory")
+
+// RuntimeFilePath returns the path of the file with the given name in the
+// private runtime directory.
+func RuntimeFilePath(name string) (string, error) {
+	if runtimeDir() == "" {
+		return "", errNoRuntimeDir
+	}
+
+	return filepath.Join(runtimeDir(), filepath.Base(name)), nil
+}
+
+// WriteRuntimeFile stores the given data into the file with the given name in
+// the private runtime directory, which is only readable by the current user.
+func WriteRuntimeFile(name string, data []byte) error {
+	path, err := RuntimeFilePath(name)
+	if err != nil {
+		return err
+	}
+
+	return WritePrivateFile(path, data)
+}
+
+// WritePrivateFile replaces the file at the given path with the given data,
+// which is only readable by the current user. Missing parent directories are
+// created with the same restriction.
+func WritePrivateFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	return writeFileAtomic(path, data, 0o600)
+}
+
+// ReadRuntimeFile returns the contents of the file with the given name in the
+// private runtime directory.
+func ReadRuntimeFile(name string) ([]byte, error) {
+	path, err := RuntimeFilePath(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.ReadFile(path)
+}
+
+// RemoveRuntimeFile removes the file with the given name from the private
+// runtime directory, if it exists.
+func RemoveRuntimeFile(name string) error {
+	path, err := RuntimeFilePath(name)
+	if err != nil {
+		return nil
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,144 @@
+// Copyright (c) 2026 SUSE LLC. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package regionsrv
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// removeCAFile removes the CA file and its checksums. The given command is run
+// afterwards if the CA file was part of the system trust store.
+func removeCAFile(cmd commander) error {
+	if caFilePath == "" {
+		return nil
+	}
+
+	unlock, err := lockDir(filepath.Dir(caFilePath))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	os.Remove(oldHashFilePath)
+	os.Remove(hashFilePath)
+
+	err = os.Remove(caFilePath)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if updateTrustStore {
+		return cmd.Run()
+	}
+
+	return nil
+}
+
+// RemoveCAFile removes the CA file written by SaveCAFile, calling
+// `update-ca-certificates` afterwards if needed.
+func RemoveCAFile() error {
+	cmd := exec.Command("update-ca-certificates")
+	return removeCAFile(cmd)
+}
+
+// RemoveHostsEntries removes all the entries written by UpdateHostsFile.
+func RemoveHostsEntries() error {
+	if hostsFile == "" {
+		return nil
+	}
+
+	unlock, err := lockDir(filepath.Dir(hostsFile))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	content, err := os.ReadFile(hostsFile)
+	if err != nil {
+		return fmt.Errorf("can't read %s file: %v", hostsFile, err.Error())
+	}
+
+	var lines []string
+	for _, line := range strings.Split(string(content), "\n") {
+		if !strings.HasSuffix(strings.TrimSpace(line), hostsMarker) {
+			lines = append(lines, line)
+		}
+	}
+
+	newcontent := strings.Join(lines, "\n")
+	if newcontent == string(content) {
+		return nil
+	}
+
+	if err := writeFileAtomic(hostsFile, []byte(newcontent), 0o644); err != nil {
+		return fmt.Errorf("can't write %s file: %v", hostsFile, err.Error())
+	}
+
+	return nil
+}
+
+// RemoveRuntimeFiles removes the files from the private runtime directory,
+// and the directory itself if it is empty afterwards, along with the key of
+// the cache.
+func RemoveRuntimeFiles() error {
+	if runtimeDir() == "" {
+		return nil
+	}
+
+	for _, path := range []string{cachePath(), cacheKeyPath()} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	// The directory may be shared with other files when given by the user.
+	os.Remove(runtimeDir())
+
+	return nil
+}
+
+// Leftovers returns a description of each file or entry written by this
+// package which is still present in the system.
+func Leftovers() []string {
+	var leftovers []string
+
+	paths := []string{caFilePath, hashFilePath, oldHashFilePath}
+	if runtimeDir() != "" {
+		paths = append(paths, cachePath())
+	}
+
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err == nil {
+			leftovers = append(leftovers, fmt.Sprintf("%s: file left by container-suseconnect", path))
+		}
+	}
+
+	if hostsFile != "" {
+		if content, err := os.ReadFile(hostsFile); err == nil && strings.Contains(string(content), hostsMarker) {
+			leftovers = append(leftovers, fmt.Sprintf("%s: entry left by container-suseconnect", hostsFile))
+		}
+	}
+
+	return leftovers
+}
@@ -0,0 +1,101 @@
+// Copyright (c) 2026 SUSE LLC. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package regionsrv
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRemoveHostsEntries(t *testing.T) {
+	restoreSideEffects(t)
+
+	hostsFile = copyHostFileToTemp(0o644)
+	if hostsFile == "" {
+		t.Fatalf("Failed to initialize hosts file")
+	}
+	defer os.Remove(hostsFile)
+
+	if err := UpdateHostsFile("test-hostname", "1.1.1.1"); err != nil {
+		t.Fatalf("Expected a nil error, got: %v", err)
+	}
+	if len(Leftovers()) == 0 {
+		t.Fatal("The hosts entry should be reported as a leftover")
+	}
+
+	if err := RemoveHostsEntries(); err != nil {
+		t.Fatalf("Expected a nil error, got: %v", err)
+	}
+
+	after, err := os.ReadFile(hostsFile)
+	if err != nil {
+		t.Fatalf("Expected a nil error, got: %v", err)
+	}
+	if strings.Contains(string(after), "test-hostname") {
+		t.Fatalf("The entry should have been removed:\n%v", string(after))
+	}
+	if !strings.Contains(string(after), "ip6-localnet") {
+		t.Fatalf("Other entries should be kept:\n%v", string(after))
+	}
+}
+
+func TestRemoveCAFile(t *testing.T) {
+	restoreSideEffects(t)
+	t.Setenv(RuntimeDirEnv, t.TempDir())
+
+	dir := t.TempDir()
+	caFilePath = filepath.Join(dir, "ca.pem")
+	hashFilePath = filepath.Join(dir, "ca.sha256")
+	hostsFile = ""
+
+	if err := saveCAFile(testCommand{}, "ca"); err != nil {
+		t.Fatalf("Expected a nil error, got: %v", err)
+	}
+	if leftovers := Leftovers(); len(leftovers) != 2 {
+		t.Fatalf("Expected the CA and its checksum as leftovers, got: %v", leftovers)
+	}
+
+	err := removeCAFile(testCommand{shouldFail: true})
+	if err == nil || err.Error() != "I AM ERROR" {
+		t.Fatalf("Expected the command to be run, got: %v", err)
+	}
+	if leftovers := Leftovers(); len(leftovers) != 0 {
+		t.Fatalf("Expected no leftovers, got: %v", leftovers)
+	}
+
+	// Nothing to be done the second time.
+	if err := removeCAFile(testCommand{shouldFail: true}); err != nil {
+		t.Fatalf("Expected a nil error, got: %v", err)
+	}
+}
+
+func TestRemoveRuntimeFiles(t *testing.T) {
+	beforeCacheTest(t)
+
+	withSuppressedLog(func() {
+		if err := writeCache(&ContainerBuildConfig{Username: "banjo"}); err != nil {
+			t.Fatalf("Expected error to be nil: %v", err)
+		}
+
+		if err := RemoveRuntimeFiles(); err != nil {
+			t.Fatalf("Expected error to be nil: %v", err)
+		}
+		if _, err := os.Stat(runtimeDir()); !os.IsNotExist(err) {
+			t.Fatalf("The runtime directory should have been removed: %v", err)
+		}
+	})
+}
@@ -17,52 +17,273 @@
 package regionsrv
 
 import (
+	"bufio"
+	"encoding/binary"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"log"
 	"net"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// configVersion is the latest version of the response schema of the
+// containerbuild-regionsrv server that is supported.
+const configVersion = 2
+
+// UpdateServer contains the data of one of the update servers of the public
+// cloud region.
+type UpdateServer struct {
+	Fqdn string `json:"fqdn"`
+	IP   string `json:"ip"`
+	// Servers with a lower priority value are preferred.
+	Priority int `json:"priority"`
+}
+
 // ContainerBuildConfig contains all the data that is available through the
 // containerbuild-regionsrv server running on the host.
+//
+// Responses without a version (or with version 1) carry a single update
+// server in `ServerFqdn` and `ServerIP`. Starting from version 2 the server
+// can provide a list of update servers in `Servers` instead.
 type ContainerBuildConfig struct {
-	InstanceData string `json:"instance-data"`
-	ServerFqdn   string `json:"server-fqdn"`
-	ServerIP     string `json:"server-ip"`
-	Username     string `json:"username"`
-	Password     string `json:"password"`
-	Ca           string `json:"ca"`
+	Version      int            `json:"version,omitempty"`
+	InstanceData string         `json:"instance-data"`
+	ServerFqdn   string         `json:"server-fqdn"`
+	ServerIP     string         `json:"server-ip"`
+	Servers      []UpdateServer `json:"servers,omitempty"`
+	Username     string         `json:"username"`
+	Password     string         `json:"password"`
+	Ca           string         `json:"ca"`
+}
+
+// UpdateServers returns all the update servers given by the configuration,
+// sorted by their priority. The server from the single-server fields is
+// included as well if it is not already part of `Servers`.
+func (cfg *ContainerBuildConfig) UpdateServers() []UpdateServer {
+	servers := make([]UpdateServer, len(cfg.Servers))
+	copy(servers, cfg.Servers)
+
+	sort.SliceStable(servers, func(i, j int) bool {
+		return servers[i].Priority < servers[j].Priority
+	})
+
+	if cfg.ServerFqdn != "" {
+		for _, server := range servers {
+			if server.Fqdn == cfg.ServerFqdn && server.IP == cfg.ServerIP {
+				return servers
+			}
+		}
+
+		servers = append(servers, UpdateServer{Fqdn: cfg.ServerFqdn, IP: cfg.ServerIP})
+	}
+
+	return servers
 }
 
-// containerBuildSrvAddress returns a string containing the full address of the TCP
-// server. You may tweak this by providing `CONTAINER_BUILD_IP` and/or
-// `CONTAINER_BUILD_PORT`, otherwise `0.0.0.0` and `7956` are taken as defaults for
-// the IP and the port respectively.
-func containerBuildSrvAddress() string {
-	ip := os.Getenv("CONTAINER_BUILD_IP")
-	if ip == "" {
-		ip = "0.0.0.0"
+// updateServerPort is the port used to check whether an update server is
+// reachable.
+var updateServerPort = "443"
+
+// SelectUpdateServer returns the first reachable update server from the given
+// configuration. If none of them could be reached, the preferred one is
+// returned so the caller can still try (e.g. through a proxy).
+func SelectUpdateServer(cfg *ContainerBuildConfig) (UpdateServer, error) {
+	servers := cfg.UpdateServers()
+	if len(servers) == 0 {
+		return UpdateServer{}, errors.New("no update server given")
+	}
+
+	for _, server := range servers {
+		host := server.IP
+		if host == "" {
+			host = server.Fqdn
+		}
+
+		conn, err := net.DialTimeout("tcp", net.JoinHostPort(host, updateServerPort), dialTimeout)
+		if err == nil {
+			conn.Close()
+			return server, nil
+		}
+
+		log.Printf("Update server '%v' not reachable: %v", server.Fqdn, err)
 	}
 
+	log.Printf("No update server reachable, using '%v'", servers[0].Fqdn)
+
+	return servers[0], nil
+}
+
+var (
+	// routeFile is the file providing the kernel's routing table.
+	routeFile = "/proc/net/route"
+
+	// hostAliases contains the names that container engines give to the host
+	// inside of bridged networks.
+	hostAliases = []string{"host.containers.internal", "host.docker.internal"}
+
+	// aliasesFile is the file in which container engines define hostAliases.
+	aliasesFile = "/etc/hosts"
+
+	// dialTimeout is the timeout for reaching each candidate address.
+	dialTimeout = 2 * time.Second
+
+	// discoveredAddress is the address in which the server was found.
+	discoveredAddress string
+)
+
+// containerBuildSrvPort returns the port of the TCP server. You may tweak this
+// by providing `CONTAINER_BUILD_PORT`, otherwise `7956` is taken as default.
+func containerBuildSrvPort() string {
 	port := os.Getenv("CONTAINER_BUILD_PORT")
 	if port == "" {
 		port = "7956"
 	}
 
-	return fmt.Sprintf("%s:%s", ip, port)
+	return port
 }
 
-// ServerReachable returns true if the containerbuild-regionsrv server is
-// reachable, false otherwise.
-func ServerReachable() error {
-	addr, err := net.ResolveTCPAddr("tcp", containerBuildSrvAddress())
+// containerBuildSrvAddresses returns the candidate addresses of the TCP
+// server, in the order in which they should be tried. You may tweak this by
+// providing `CONTAINER_BUILD_IP`, which will then be the only candidate.
+// Otherwise `0.0.0.0` is tried first, which only works with host networking,
+// followed by the default gateway of the container and the names given to the
+// host by container engines on bridged networks. These names are only taken
+// from the hosts file, since anyone answering for them through DNS would
+// provide the credentials and the CA otherwise.
+func containerBuildSrvAddresses() []string {
+	port := containerBuildSrvPort()
+
+	if ip := os.Getenv("CONTAINER_BUILD_IP"); ip != "" {
+		return []string{net.JoinHostPort(ip, port)}
+	}
+
+	hosts := []string{"0.0.0.0"}
+	if gw, err := defaultGateway(); err == nil {
+		hosts = append(hosts, gw)
+	}
+	for _, alias := range hostAliases {
+		if ip := aliasAddress(alias); ip != "" {
+			hosts = append(hosts, ip)
+		}
+	}
+
+	addrs := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		addrs = append(addrs, net.JoinHostPort(host, port))
+	}
+
+	return addrs
+}
+
+// aliasAddress returns the IP given to the given name by the hosts file, or
+// an empty string if it is not defined there.
+func aliasAddress(name string) string {
+	file, err := os.Open(aliasesFile)
 	if err != nil {
-		return err
+		return ""
 	}
+	defer file.Close()
 
-	conn, err := net.DialTCP("tcp", nil, addr)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) < 2 || net.ParseIP(fields[0]) == nil {
+			continue
+		}
+
+		for _, host := range fields[1:] {
+			if host == name {
+				return fields[0]
+			}
+		}
+	}
+
+	return ""
+}
+
+// defaultGateway returns the IP of the default gateway as given by the
+// kernel's routing table.
+func defaultGateway() (string, error) {
+	file, err := os.Open(routeFile)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// Iface Destination Gateway Flags RefCnt Use Metric Mask ...
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || fields[1] != "00000000" {
+			continue
+		}
+
+		flags, err := strconv.ParseUint(fields[3], 16, 16)
+		if err != nil || flags&0x2 == 0 {
+			continue
+		}
+
+		// The kernel prints the gateway address, which is in network byte
+		// order, as an hexadecimal number in host byte order.
+		gw, err := strconv.ParseUint(fields[2], 16, 32)
+		if err != nil {
+			continue
+		}
+
+		ip := make(net.IP, 4)
+		binary.NativeEndian.PutUint32(ip, uint32(gw))
+
+		return ip.String(), nil
+	}
+
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+
+	return "", errors.New("no default gateway found")
+}
+
+// dialServer connects to the containerbuild-regionsrv server by trying all
+// the candidate addresses. The address which succeeded is remembered for
+// further calls. If no candidate could be reached, the error for the first
+// one is returned.
+func dialServer() (net.Conn, error) {
+	addrs := containerBuildSrvAddresses()
+	if discoveredAddress != "" {
+		addrs = append([]string{discoveredAddress}, addrs...)
+	}
+
+	var firstErr error
+
+	for _, addr := range addrs {
+		log.Printf("Trying to reach suse build server at '%v'", addr)
+
+		conn, err := net.DialTimeout("tcp", addr, dialTimeout)
+		if err == nil {
+			if discoveredAddress != addr {
+				log.Printf("containerbuild-regionsrv found at '%v'", addr)
+				discoveredAddress = addr
+			}
+			return conn, nil
+		}
+
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return nil, firstErr
+}
+
+// ServerReachable returns true if the containerbuild-regionsrv server is
+// reachable, false otherwise.
+func ServerReachable() error {
+	conn, err := dialServer()
 	if err != nil {
 		return err
 	}
@@ -74,15 +295,10 @@ func ServerReachable() error {
 
 // ReadConfigFromServer performs a request against the containerbuild-regionsrv
 // server running in the host, and it parses the given response so it can be
-// used as a ContainerBuildConfig.
+// used as a ContainerBuildConfig. The response is validated, so a
+// ValidationError is returned if it cannot be applied to the system.
 func ReadConfigFromServer() (*ContainerBuildConfig, error) {
-	addr, err := net.ResolveTCPAddr("tcp", containerBuildSrvAddress())
-	log.Printf("Trying to reach suse build server at '%v'", addr.String())
-	if err != nil {
-		return nil, err
-	}
-
-	conn, err := net.DialTCP("tcp", nil, addr)
+	conn, err := dialServer()
 	if err != nil {
 		return nil, err
 	}
@@ -99,9 +315,18 @@ func ReadConfigFromServer() (*ContainerBuildConfig, error) {
 
 	// If something is really bad on the server side, it may return an empty
 	// response. Catch this error here.
-	if data.InstanceData == "" && data.ServerFqdn == "" && data.ServerIP == "" && data.Ca == "" {
+	if data.InstanceData == "" && data.ServerFqdn == "" && data.ServerIP == "" &&
+		len(data.Servers) == 0 && data.Ca == "" {
 		return nil, errors.New("empty response from the server")
 	}
 
+	if data.Version > configVersion {
+		log.Printf("Warning: unknown version %v of the server response, trying anyway", data.Version)
+	}
+
+	if err := data.Validate(); err != nil {
+		return nil, err
+	}
+
 	return data, nil
 }
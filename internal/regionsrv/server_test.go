@@ -89,9 +89,25 @@ func withSuppressedLog(fn func()) {
 	log.SetOutput(os.Stdout)
 }
 
+// withoutDiscovery restricts the candidate addresses of the server to the
+// default one for the duration of the test.
+func withoutDiscovery(t *testing.T) {
+	oldRoute, oldAliases, oldAliasesFile := routeFile, hostAliases, aliasesFile
+	t.Cleanup(func() {
+		routeFile, hostAliases, aliasesFile = oldRoute, oldAliases, oldAliasesFile
+		discoveredAddress = ""
+	})
+
+	routeFile = fixturesPath("route-nogw")
+	hostAliases = nil
+	discoveredAddress = ""
+}
+
 // Test suite below
 
 func TestReadConfigFromServerFailConnection(t *testing.T) {
+	withoutDiscovery(t)
+
 	withSuppressedLog(func() {
 		_, err := ReadConfigFromServer()
 		if !strings.Contains(err.Error(), "connection refused") {
@@ -161,6 +177,8 @@ func TestValidResponse(t *testing.T) {
 }
 
 func TestServerReachableNope(t *testing.T) {
+	withoutDiscovery(t)
+
 	withSuppressedLog(func() {
 		err := ServerReachable()
 		if !strings.Contains(err.Error(), "connection refused") {
@@ -183,3 +201,180 @@ func TestServerReachableSuccessful(t *testing.T) {
 		}
 	})
 }
+
+func TestDefaultGateway(t *testing.T) {
+	withoutDiscovery(t)
+
+	routeFile = fixturesPath("route")
+	gw, err := defaultGateway()
+	if err != nil {
+		t.Fatalf("should be nil but got: %v", err)
+	}
+	if gw != "127.0.0.2" {
+		t.Fatalf("Expected '127.0.0.2', got '%v'", gw)
+	}
+
+	routeFile = fixturesPath("route-nogw")
+	if _, err := defaultGateway(); err == nil || err.Error() != "no default gateway found" {
+		t.Fatalf("should be a 'no default gateway found' error, got '%v'", err)
+	}
+}
+
+func TestContainerBuildSrvAddresses(t *testing.T) {
+	withoutDiscovery(t)
+
+	routeFile = fixturesPath("route")
+	hostAliases = []string{"host.containers.internal", "host.docker.internal"}
+	aliasesFile = fixturesPath("hosts-aliases")
+	t.Setenv("CONTAINER_BUILD_IP", "")
+	t.Setenv("CONTAINER_BUILD_PORT", "")
+
+	// host.docker.internal is not in the hosts file, so it is never looked
+	// up through DNS.
+	addrs := containerBuildSrvAddresses()
+	expected := []string{"0.0.0.0:7956", "127.0.0.2:7956", "10.88.0.1:7956"}
+	if strings.Join(addrs, ",") != strings.Join(expected, ",") {
+		t.Fatalf("Expected '%v', got '%v'", expected, addrs)
+	}
+
+	t.Setenv("CONTAINER_BUILD_IP", "10.0.0.1")
+	t.Setenv("CONTAINER_BUILD_PORT", "1234")
+
+	addrs = containerBuildSrvAddresses()
+	if len(addrs) != 1 || addrs[0] != "10.0.0.1:1234" {
+		t.Fatalf("Expected only the given address, got '%v'", addrs)
+	}
+}
+
+func TestServerReachableThroughGateway(t *testing.T) {
+	withoutDiscovery(t)
+
+	// The server only listens on the address of the default gateway, so the
+	// default address is not reachable.
+	server, err := net.Listen("tcp", "127.0.0.2:0")
+	if err != nil {
+		t.Skipf("could not listen on the gateway address: %v", err)
+	}
+	defer server.Close()
+
+	_, port, _ := net.SplitHostPort(server.Addr().String())
+	t.Setenv("CONTAINER_BUILD_IP", "")
+	t.Setenv("CONTAINER_BUILD_PORT", port)
+	routeFile = fixturesPath("route")
+
+	withSuppressedLog(func() {
+		if err := ServerReachable(); err != nil {
+			t.Fatalf("should be nil but got: %v", err)
+		}
+	})
+
+	if discoveredAddress != server.Addr().String() {
+		t.Fatalf("Expected '%v' to be discovered, got '%v'", server.Addr().String(), discoveredAddress)
+	}
+}
+
+func TestUpdateServersLegacy(t *testing.T) {
+	cfg := &ContainerBuildConfig{ServerFqdn: "smt.test.lan", ServerIP: "1.1.1.1"}
+
+	servers := cfg.UpdateServers()
+	if len(servers) != 1 || servers[0].Fqdn != "smt.test.lan" || servers[0].IP != "1.1.1.1" {
+		t.Fatalf("Unexpected servers: %v", servers)
+	}
+}
+
+func TestUpdateServersVersioned(t *testing.T) {
+	var cfg ContainerBuildConfig
+
+	data := `{"version": 2, "instance-data": "data",
+		"server-fqdn": "smt2.test.lan", "server-ip": "2.2.2.2",
+		"servers": [
+			{"fqdn": "smt3.test.lan", "ip": "3.3.3.3", "priority": 20},
+			{"fqdn": "smt1.test.lan", "ip": "1.1.1.1", "priority": 10},
+			{"fqdn": "smt2.test.lan", "ip": "2.2.2.2", "priority": 30}
+		]}`
+	if err := json.Unmarshal([]byte(data), &cfg); err != nil {
+		t.Fatalf("should be nil but got: %v", err)
+	}
+
+	servers := cfg.UpdateServers()
+	expected := []string{"smt1.test.lan", "smt3.test.lan", "smt2.test.lan"}
+	if len(servers) != len(expected) {
+		t.Fatalf("Expected %v servers, got %v", len(expected), servers)
+	}
+
+	for i, fqdn := range expected {
+		if servers[i].Fqdn != fqdn {
+			t.Fatalf("Expected '%v' at position %v, got '%v'", fqdn, i, servers[i].Fqdn)
+		}
+	}
+}
+
+func TestVersionedResponse(t *testing.T) {
+	withoutDiscovery(t)
+
+	withSuppressedLog(func() {
+		ts := &testServer{
+			bootstrapped: make(chan bool, 1),
+			response: ContainerBuildConfig{
+				Version: configVersion,
+				Servers: []UpdateServer{{Fqdn: "smt1.test.lan", IP: "1.1.1.1"}},
+			},
+		}
+		defer ts.close()
+
+		go ts.run()
+		<-ts.bootstrapped
+
+		cfg, err := ReadConfigFromServer()
+		if err != nil {
+			t.Fatalf("should be nil but got: %v", err)
+		}
+
+		if len(cfg.UpdateServers()) != 1 {
+			t.Fatalf("Expected one update server, got %v", cfg.UpdateServers())
+		}
+	})
+}
+
+func TestSelectUpdateServer(t *testing.T) {
+	server, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not start test server: %v", err)
+	}
+	defer server.Close()
+
+	old := updateServerPort
+	defer func() { updateServerPort = old }()
+	_, updateServerPort, _ = net.SplitHostPort(server.Addr().String())
+
+	cfg := &ContainerBuildConfig{
+		Servers: []UpdateServer{
+			{Fqdn: "down.test.lan", IP: "127.0.0.2", Priority: 1},
+			{Fqdn: "up.test.lan", IP: "127.0.0.1", Priority: 2},
+		},
+	}
+
+	withSuppressedLog(func() {
+		selected, err := SelectUpdateServer(cfg)
+		if err != nil {
+			t.Fatalf("should be nil but got: %v", err)
+		}
+		if selected.Fqdn != "up.test.lan" {
+			t.Fatalf("Expected 'up.test.lan', got '%v'", selected.Fqdn)
+		}
+
+		// Nothing reachable: fall back to the preferred server.
+		server.Close()
+		selected, err = SelectUpdateServer(cfg)
+		if err != nil {
+			t.Fatalf("should be nil but got: %v", err)
+		}
+		if selected.Fqdn != "down.test.lan" {
+			t.Fatalf("Expected 'down.test.lan', got '%v'", selected.Fqdn)
+		}
+
+		if _, err := SelectUpdateServer(&ContainerBuildConfig{}); err == nil {
+			t.Fatal("Expected an error when there are no servers")
+		}
+	})
+}
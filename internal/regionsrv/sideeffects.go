@@ -0,0 +1,80 @@
+// Copyright (c) 2026 SUSE LLC. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package regionsrv
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// updateTrustStore is false when the CA file is not stored in the system
+// trust store, so `update-ca-certificates` must not be run.
+var updateTrustStore = true
+
+// writable returns true if the current user can write into the given path.
+// This also takes read-only mounts into account.
+func writable(path string) bool {
+	// 0x2 is W_OK from access(2).
+	return syscall.Access(path, 0x2) == nil
+}
+
+// runtimeDirWritable returns true if the default runtime directory either
+// exists and is writable, or it can be created.
+func runtimeDirWritable() bool {
+	if _, err := os.Stat(defaultRuntimeDir); err == nil {
+		return writable(defaultRuntimeDir)
+	}
+
+	return writable(filepath.Dir(defaultRuntimeDir))
+}
+
+// ConfigureSideEffects makes sure that this package does not touch any
+// location of the system which is not writable, so it works on read-only root
+// filesystems and for non-root users. If `dir` is not empty, every file that
+// would be written into the system is redirected into it instead. Otherwise
+// only the locations which are not writable are skipped. It returns a
+// description of each feature that is degraded because of it.
+func ConfigureSideEffects(dir string) []string {
+	var degraded []string
+
+	if dir != "" {
+		caFilePath = filepath.Join(dir, filepath.Base(caFilePath))
+		hashFilePath = filepath.Join(dir, filepath.Base(hashFilePath))
+		oldHashFilePath = filepath.Join(dir, filepath.Base(oldHashFilePath))
+		updateTrustStore = false
+		degraded = append(degraded, fmt.Sprintf("the CA of the update server is stored in %s, but it is not added to the system trust store", caFilePath))
+	} else if !writable(filepath.Dir(caFilePath)) {
+		degraded = append(degraded, fmt.Sprintf("the CA of the update server is not installed, %s is not writable", filepath.Dir(caFilePath)))
+		caFilePath = ""
+	}
+
+	if dir != "" || !writable(hostsFile) {
+		degraded = append(degraded, fmt.Sprintf("no entry for the update server is added to %s, zypper has to resolve it through DNS", hostsFile))
+		hostsFile = ""
+	}
+
+	if os.Getenv(RuntimeDirEnv) == "" {
+		if dir != "" {
+			defaultRuntimeDir = filepath.Join(dir, "run")
+		} else if !runtimeDirWritable() {
+			degraded = append(degraded, fmt.Sprintf("the configuration from containerbuild-regionsrv is not cached, %s is not writable", defaultRuntimeDir))
+			defaultRuntimeDir = ""
+		}
+	}
+
+	return degraded
+}
@@ -0,0 +1,121 @@
+// Copyright (c) 2026 SUSE LLC. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package regionsrv
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// restoreSideEffects restores the paths touched by ConfigureSideEffects when
+// the test finishes.
+func restoreSideEffects(t *testing.T) {
+	ca, hash, oldHash, hosts, runtime := caFilePath, hashFilePath, oldHashFilePath, hostsFile, defaultRuntimeDir
+	t.Cleanup(func() {
+		caFilePath, hashFilePath, oldHashFilePath = ca, hash, oldHash
+		hostsFile, defaultRuntimeDir = hosts, runtime
+		updateTrustStore = true
+	})
+}
+
+// Tests start here
+
+func TestConfigureSideEffectsRedirected(t *testing.T) {
+	restoreSideEffects(t)
+	t.Setenv(RuntimeDirEnv, "")
+	dir := t.TempDir()
+	caFilePath = "/etc/pki/trust/anchors/containerbuild-regionsrv.pem"
+
+	withSuppressedLog(func() {
+		degraded := ConfigureSideEffects(dir)
+		if len(degraded) != 2 {
+			t.Fatalf("Expected two degraded features, got: %v", degraded)
+		}
+
+		if runtimeDir() != filepath.Join(dir, "run") {
+			t.Fatalf("Unexpected runtime directory '%v'", runtimeDir())
+		}
+
+		// The trust store is not updated, so the command is never run.
+		if err := saveCAFile(testCommand{shouldFail: true}, "ca"); err != nil {
+			t.Fatalf("Expected error to be nil: %v", err)
+		}
+		if filepath.Dir(caFilePath) != dir {
+			t.Fatalf("Expected the CA in the writable directory, got '%v'", caFilePath)
+		}
+		data, err := os.ReadFile(caFilePath)
+		if err != nil || string(data) != "ca" {
+			t.Fatalf("Expected the CA in the writable directory, got '%s' (%v)", data, err)
+		}
+
+		if err := UpdateHostsFile("smt.test.lan", "1.1.1.1"); err != nil {
+			t.Fatalf("Expected error to be nil: %v", err)
+		}
+	})
+}
+
+func TestConfigureSideEffectsSkipped(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root can write into any directory")
+	}
+
+	restoreSideEffects(t)
+	t.Setenv(RuntimeDirEnv, "")
+
+	readOnly := t.TempDir()
+	if err := os.Chmod(readOnly, 0o500); err != nil {
+		t.Fatalf("Could not change permissions: %v", err)
+	}
+	t.Cleanup(func() { os.Chmod(readOnly, 0o700) })
+
+	caFilePath = filepath.Join(readOnly, "anchors", "ca.pem")
+	hostsFile = filepath.Join(readOnly, "hosts")
+	defaultRuntimeDir = filepath.Join(readOnly, "run")
+
+	withSuppressedLog(func() {
+		degraded := ConfigureSideEffects("")
+		if len(degraded) != 3 {
+			t.Fatalf("Expected three degraded features, got: %v", degraded)
+		}
+
+		if err := SaveCAFile("ca"); err != nil {
+			t.Fatalf("Expected error to be nil: %v", err)
+		}
+		if err := UpdateHostsFile("smt.test.lan", "1.1.1.1"); err != nil {
+			t.Fatalf("Expected error to be nil: %v", err)
+		}
+		if cacheTTL() != 0 {
+			t.Fatal("The cache should be disabled")
+		}
+	})
+}
+
+func TestConfigureSideEffectsWritable(t *testing.T) {
+	restoreSideEffects(t)
+	t.Setenv(RuntimeDirEnv, "")
+
+	dir := t.TempDir()
+	caFilePath = filepath.Join(dir, "ca.pem")
+	hostsFile = filepath.Join(dir, "hosts")
+	defaultRuntimeDir = filepath.Join(dir, "run")
+	if err := os.WriteFile(hostsFile, nil, 0o644); err != nil {
+		t.Fatalf("Could not write hosts file: %v", err)
+	}
+
+	if degraded := ConfigureSideEffects(""); len(degraded) != 0 {
+		t.Fatalf("Nothing should be degraded, got: %v", degraded)
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright (c) 2026 SUSE LLC. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package regionsrv
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// ValidationError is returned when the data given by the
+// containerbuild-regionsrv server cannot be trusted to be applied to the
+// system.
+type ValidationError struct {
+	// Field is the field of the ContainerBuildConfig that failed validation.
+	Field string
+	// Reason describes why the field is not valid.
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid %s from containerbuild-regionsrv: %s", e.Field, e.Reason)
+}
+
+// validateHostname returns an error if the given name is not a syntactically
+// valid hostname as defined in RFC 1123.
+func validateHostname(name string) error {
+	fqdn := strings.TrimSuffix(name, ".")
+
+	if fqdn == "" {
+		return &ValidationError{Field: "server-fqdn", Reason: "empty hostname"}
+	}
+	if len(fqdn) > 253 {
+		return &ValidationError{Field: "server-fqdn", Reason: "hostname is too long"}
+	}
+
+	for _, label := range strings.Split(fqdn, ".") {
+		if len(label) == 0 || len(label) > 63 {
+			return &ValidationError{Field: "server-fqdn", Reason: fmt.Sprintf("bad label length in '%s'", name)}
+		}
+
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return &ValidationError{Field: "server-fqdn", Reason: fmt.Sprintf("bad hyphen in '%s'", name)}
+		}
+
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+				return &ValidationError{Field: "server-fqdn", Reason: fmt.Sprintf("bad character in '%s'", name)}
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateIP returns an error if the given string is not an IP address.
+func validateIP(ip string) error {
+	if net.ParseIP(ip) == nil {
+		return &ValidationError{Field: "server-ip", Reason: fmt.Sprintf("'%s' is not an IP address", ip)}
+	}
+
+	return nil
+}
+
+// validateCA returns an error unless the given contents are PEM-encoded CA
+// certificates which are valid at the given time.
+func validateCA(contents string, now time.Time) error {
+	rest := []byte(contents)
+	found := 0
+
+	for {
+		var block *pem.Block
+
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+
+		if block.Type != "CERTIFICATE" {
+			return &ValidationError{Field: "ca", Reason: fmt.Sprintf("unexpected PEM block '%s'", block.Type)}
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return &ValidationError{Field: "ca", Reason: err.Error()}
+		}
+
+		if !cert.BasicConstraintsValid || !cert.IsCA {
+			return &ValidationError{Field: "ca", Reason: fmt.Sprintf("'%s' is not a CA certificate", cert.Subject)}
+		}
+
+		if now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+			return &ValidationError{Field: "ca", Reason: fmt.Sprintf("'%s' is not valid at this time", cert.Subject)}
+		}
+
+		found++
+	}
+
+	if found == 0 {
+		return &ValidationError{Field: "ca", Reason: "no PEM-encoded certificate found"}
+	}
+
+	if strings.TrimSpace(string(rest)) != "" {
+		return &ValidationError{Field: "ca", Reason: "trailing data after the certificates"}
+	}
+
+	return nil
+}
+
+// Validate returns a ValidationError if any of the update servers or the CA
+// of the configuration is not valid. The configuration must be validated
+// before applying it to the system (e.g. the hosts file or the trust store).
+func (cfg *ContainerBuildConfig) Validate() error {
+	for _, server := range cfg.UpdateServers() {
+		if err := validateHostname(server.Fqdn); err != nil {
+			return err
+		}
+
+		if err := validateIP(server.IP); err != nil {
+			return err
+		}
+	}
+
+	if cfg.Ca != "" {
+		return validateCA(cfg.Ca, time.Now())
+	}
+
+	return nil
+}
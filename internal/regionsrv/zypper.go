@@ -22,37 +22,68 @@ import (
 	"io"
 	"log"
 	"net/url"
-	"os"
+	"sort"
 	"strings"
 )
 
-// ParseStdin parses the standard input as given by zypper and returns a map
-// with the parsed parameters.
-func ParseStdin() (map[string]string, error) {
-	params := make(map[string]string)
-	first := true
-
-	// The zypper plugin protocol is based on STOMP. STOMP messages
-	// are NUL-terminated. So read the entire message first
-	reader := bufio.NewReader(os.Stdin)
-	msg, err := reader.ReadBytes(0)
-	if err != nil && err != io.EOF {
-		return nil, err
+// Commands of the zypp plugin protocol handled by the URL resolver.
+const (
+	resolveURLCommand  = "RESOLVEURL"
+	resolvedURLCommand = "RESOLVEDURL"
+	disconnectCommand  = "_DISCONNECT"
+	ackCommand         = "ACK"
+	errorCommand       = "ERROR"
+	noMethodCommand    = "_ENOMETHOD"
+)
+
+// Frame is a single message of the zypp plugin protocol, which is based on
+// STOMP. A frame consists of a command line, a set of `key:value` headers, an
+// empty line and a body which is terminated by a NUL byte.
+type Frame struct {
+	Command string
+	Headers map[string]string
+	Body    string
+}
+
+// ResolveFunc computes the response for the headers of a RESOLVEURL frame as
+// sent by zypper.
+type ResolveFunc func(params map[string]string) (*Frame, error)
+
+// ReadFrame reads the next NUL-terminated frame from the given reader. It
+// returns io.EOF if the reader has been exhausted before any frame data was
+// read.
+func ReadFrame(reader *bufio.Reader) (*Frame, error) {
+	var msg []byte
+
+	// STOMP allows an arbitrary number of EOLs between frames, so skip
+	// messages which are empty after trimming them.
+	for len(msg) == 0 {
+		data, err := reader.ReadBytes(0)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		msg = bytes.TrimLeft(bytes.TrimSuffix(data, []byte{0}), "\r\n")
+		if len(msg) == 0 && err == io.EOF {
+			return nil, io.EOF
+		}
 	}
 
-	// Now read the message line by line. URL resolver plugin messages
-	// are just <key>:<value> header lines and don't contain a body.
-	sr := bytes.NewReader(msg)
-	scanner := bufio.NewScanner(sr)
+	head, body, _ := bytes.Cut(msg, []byte("\n\n"))
+	frame := &Frame{Headers: make(map[string]string), Body: string(body)}
 
+	scanner := bufio.NewScanner(bytes.NewReader(head))
 	for scanner.Scan() {
-		if first {
-			first = false
-		} else {
-			vals := strings.SplitN(scanner.Text(), ":", 2)
-			if len(vals) == 2 {
-				params[vals[0]] = vals[1]
-			}
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+
+		if frame.Command == "" {
+			frame.Command = line
+			continue
+		}
+
+		// URL resolver plugin messages are just <key>:<value> header lines.
+		if key, value, found := strings.Cut(line, ":"); found {
+			frame.Headers[key] = value
 		}
 	}
 
@@ -60,45 +91,174 @@ func ParseStdin() (map[string]string, error) {
 		return nil, scanner.Err()
 	}
 
-	return params, nil
+	return frame, nil
+}
+
+// WriteFrame writes the given frame into the given writer with the format
+// expected by zypper.
+func WriteFrame(w io.Writer, frame *Frame) error {
+	var buf bytes.Buffer
+
+	buf.WriteString(frame.Command + "\n")
+
+	// Sort the headers so the output is stable.
+	keys := make([]string, 0, len(frame.Headers))
+	for key := range frame.Headers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fmt.Fprintf(&buf, "%s:%s\n", key, frame.Headers[key])
+	}
+
+	// Add an extra empty line to separate Headers from payload. The message
+	// needs to be NUL-terminated.
+	buf.WriteString("\n")
+	buf.WriteString(frame.Body)
+	buf.WriteByte(0)
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// errorFrame returns an ERROR frame carrying the message of the given error.
+func errorFrame(err error) *Frame {
+	msg := strings.ReplaceAll(err.Error(), "\n", " ")
+
+	return &Frame{
+		Command: errorCommand,
+		Headers: map[string]string{"message": msg},
+		Body:    msg,
+	}
+}
+
+// ServeURLResolver implements the zypp URL resolver plugin protocol. It reads
+// frames from the given reader until zypper disconnects or the input is
+// exhausted, and it answers every RESOLVEURL frame with the frame computed by
+// `resolve`. Failures on resolving a URL are reported back to zypper as ERROR
+// frames, and only I/O errors on the plugin's channel are returned.
+func ServeURLResolver(in io.Reader, out io.Writer, resolve ResolveFunc) error {
+	reader := bufio.NewReader(in)
+
+	for {
+		frame, err := ReadFrame(reader)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		var response *Frame
+
+		switch frame.Command {
+		case resolveURLCommand:
+			response, err = resolve(frame.Headers)
+			if err != nil {
+				log.Printf("Could not resolve URL: %v", err)
+				response = errorFrame(err)
+			}
+		case disconnectCommand:
+			return WriteFrame(out, &Frame{Command: ackCommand})
+		default:
+			log.Printf("Unknown command '%v' from zypper", frame.Command)
+			response = &Frame{Command: noMethodCommand}
+		}
+
+		if err := WriteFrame(out, response); err != nil {
+			return err
+		}
+	}
 }
 
-// PrintResponse prints to standard output with the format expected by zypper.
-func PrintResponse(params map[string]string) error {
-	cfg, err := ReadConfigFromServer()
+// ResolveFromServer is a ResolveFunc which resolves the path requested by
+// zypper against the update server as given by the containerbuild-regionsrv
+// server. The server's configuration is cached as described in
+// ReadCachedConfig.
+func ResolveFromServer(params map[string]string) (*Frame, error) {
+	cfg, changed, err := ReadCachedConfig()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Error out if we have no information on the credentials.
 	if cfg.Username == "" && cfg.Password == "" {
-		return errors.New("no credentials given")
+		return nil, errors.New("no credentials given")
+	}
+
+	// Save the contents of the CA file if it doesn't exist already. This only
+	// has to be re-checked when the configuration was not cached already.
+	if changed {
+		if err = SaveCAFile(cfg.Ca); err != nil {
+			invalidateCache()
+			return nil, err
+		}
+	}
+
+	server, err := resolveUpdateServer(cfg, changed)
+	if err != nil {
+		return nil, err
 	}
 
-	// Save the contents of the CA file if it doesn't exist already.
-	if err = SaveCAFile(cfg.Ca); err != nil {
-		return err
+	return frameFromConfiguration(params["path"], server, cfg), nil
+}
+
+// resolvedServer is the update server selected for the zypper session handled
+// by this process, since zypper asks to resolve every single repository.
+var resolvedServer *UpdateServer
+
+// resolveUpdateServer returns the update server to be used for the given
+// configuration. The server is only selected again when the configuration
+// changed, and the hosts file is only updated when the selection did.
+func resolveUpdateServer(cfg *ContainerBuildConfig, changed bool) (UpdateServer, error) {
+	if resolvedServer != nil && !changed {
+		return *resolvedServer, nil
 	}
 
-	printFromConfiguration(params["path"], cfg)
+	server, err := SelectUpdateServer(cfg)
+	if err != nil {
+		return UpdateServer{}, err
+	}
 
-	return nil
+	// zypper needs to resolve the FQDN of the selected update server.
+	if server.IP != "" && (resolvedServer == nil || *resolvedServer != server) {
+		if err = UpdateHostsFile(server.Fqdn, server.IP); err != nil {
+			return UpdateServer{}, err
+		}
+	}
+
+	resolvedServer = &server
+	return server, nil
 }
 
-func printFromConfiguration(path string, cfg *ContainerBuildConfig) {
-	u := url.URL{
+// frameFromConfiguration returns the RESOLVEDURL frame for the given path,
+// update server and configuration.
+func frameFromConfiguration(path string, server UpdateServer, cfg *ContainerBuildConfig) *Frame {
+	u := &url.URL{
 		Scheme: "https",
-		Host:   cfg.ServerFqdn,
+		Host:   server.Fqdn,
 		Path:   path,
 		User:   url.UserPassword(cfg.Username, cfg.Password),
 	}
 
 	log.Print("Received X-Instance-Data")
+
+	return NewResolvedURLFrame(u, map[string]string{"X-Instance-Data": cfg.InstanceData})
+}
+
+// NewResolvedURLFrame returns the RESOLVEDURL frame pointing zypper to the
+// given URL. The given headers will be passed by zypper on every request
+// against the resolved URL.
+func NewResolvedURLFrame(u *url.URL, headers map[string]string) *Frame {
 	log.Printf("Resulting URL: %s", u.Redacted())
 
-	fmt.Printf("RESOLVEDURL\n")
-	// Add an extra empty line to separate Headers from payload
-	fmt.Printf("X-Instance-Data:%s\n\n", cfg.InstanceData)
-	// Message needs to be NUL-terminated
-	fmt.Printf("%s\000", u.String())
+	if headers == nil {
+		headers = make(map[string]string)
+	}
+
+	return &Frame{
+		Command: resolvedURLCommand,
+		Headers: headers,
+		Body:    u.String(),
+	}
 }
@@ -15,72 +15,102 @@
 package regionsrv
 
 import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
-
-	"github.com/mssola/capture"
 )
 
-func TestParseStdinSuccessful(t *testing.T) {
-	content := []byte("RESOLVEURL\nkey:value1\nanother:value2")
-	tmp, err := os.CreateTemp("", "file")
+func TestReadFrameSuccessful(t *testing.T) {
+	reader := bufio.NewReader(strings.NewReader("RESOLVEURL\nkey:value1\nanother:value2"))
+
+	frame, err := ReadFrame(reader)
 	if err != nil {
-		t.Fatalf("Initialization error: %v", err)
+		t.Fatalf("ReadFrame returned an error: %v", err)
 	}
 
-	defer func() {
-		tmp.Close()
-		os.Remove(tmp.Name())
-	}()
-
-	if _, err := tmp.Write(content); err != nil {
-		t.Fatalf("Initialization error: %v", err)
+	if frame.Command != "RESOLVEURL" {
+		t.Fatalf("Expected command to be 'RESOLVEURL', got '%v' instead", frame.Command)
 	}
 
-	if _, err := tmp.Seek(0, 0); err != nil {
-		t.Fatalf("Initialization error: %v", err)
+	if len(frame.Headers) != 2 {
+		t.Fatalf("There should be two entries, %v instead", len(frame.Headers))
 	}
 
-	// stdin switcheroo
-	old := os.Stdin
-	defer func() {
-		os.Stdin = old
-	}()
-	os.Stdin = tmp
-
-	params, err := ParseStdin()
-	if err != nil {
-		t.Fatalf("ParseStdin returned an error: %v", err)
+	if frame.Headers["key"] != "value1" {
+		t.Fatalf("Expected 'key' to contain 'value1', got '%v' instead", frame.Headers["key"])
 	}
 
-	if len(params) != 2 {
-		t.Fatalf("There should be two entries, %v instead", len(params))
+	if frame.Headers["another"] != "value2" {
+		t.Fatalf("Expected 'key' to contain 'value2', got '%v' instead", frame.Headers["another"])
 	}
+}
 
-	if params["key"] != "value1" {
-		t.Fatalf("Expected 'key' to contain 'value1', got '%v' instead", params["key"])
+func TestReadFrameMultipleFrames(t *testing.T) {
+	input := "RESOLVEURL\npath:/one\n\n\x00\nRESOLVEURL\npath:/two\n\nbody\x00\n"
+	reader := bufio.NewReader(strings.NewReader(input))
+
+	for _, expected := range []string{"/one", "/two"} {
+		frame, err := ReadFrame(reader)
+		if err != nil {
+			t.Fatalf("ReadFrame returned an error: %v", err)
+		}
+
+		if frame.Headers["path"] != expected {
+			t.Fatalf("Expected path '%v', got '%v' instead", expected, frame.Headers["path"])
+		}
 	}
 
-	if params["another"] != "value2" {
-		t.Fatalf("Expected 'key' to contain 'value2', got '%v' instead", params["another"])
+	if _, err := ReadFrame(reader); err != io.EOF {
+		t.Fatalf("Expected io.EOF, got '%v' instead", err)
 	}
 }
 
-func TestParseStdinBadInput(t *testing.T) {
-	old := os.Stdin
-	defer func() {
-		os.Stdin = old
-	}()
-	os.Stdin = nil
-
-	_, err := ParseStdin()
+func TestReadFrameBadInput(t *testing.T) {
+	_, err := ReadFrame(bufio.NewReader((*os.File)(nil)))
 	if err.Error() != "invalid argument" {
 		t.Fatalf("Expected there to be an invalid argument error, got '%v' instead", err)
 	}
 }
 
-func TestPrintResponseBadResponseFromServer(t *testing.T) {
+func TestServeURLResolver(t *testing.T) {
+	withSuppressedLog(func() {
+		input := "RESOLVEURL\npath:/one\n\n\x00" +
+			"RESOLVEURL\npath:/fail\n\n\x00" +
+			"UNKNOWN\n\n\x00" +
+			"_DISCONNECT\n\n\x00" +
+			"RESOLVEURL\npath:/ignored\n\n\x00"
+
+		resolve := func(params map[string]string) (*Frame, error) {
+			if params["path"] == "/fail" {
+				return nil, errors.New("I AM ERROR")
+			}
+			return &Frame{Command: "RESOLVEDURL", Body: "https://test.fqdn.com" + params["path"]}, nil
+		}
+
+		var out bytes.Buffer
+		if err := ServeURLResolver(strings.NewReader(input), &out, resolve); err != nil {
+			t.Fatalf("Expected error to be nil, got: %v", err)
+		}
+
+		expected := "RESOLVEDURL\n\nhttps://test.fqdn.com/one\x00" +
+			"ERROR\nmessage:I AM ERROR\n\nI AM ERROR\x00" +
+			"_ENOMETHOD\n\n\x00" +
+			"ACK\n\n\x00"
+		if out.String() != expected {
+			t.Fatalf("Expected %q, got %q", expected, out.String())
+		}
+	})
+}
+
+func TestResolveFromServerBadResponseFromServer(t *testing.T) {
+	t.Setenv(RuntimeDirEnv, t.TempDir())
+
 	withSuppressedLog(func() {
 		ts := &testServer{
 			bootstrapped: make(chan bool, 1),
@@ -93,14 +123,16 @@ func TestPrintResponseBadResponseFromServer(t *testing.T) {
 
 		params := map[string]string{}
 
-		err := PrintResponse(params)
+		_, err := ResolveFromServer(params)
 		if err == nil || err.Error() != "empty response from the server" {
 			t.Fatalf("expecting an error from ReadConfigFromServer, got %v", err)
 		}
 	})
 }
 
-func TestPrintResponseNoCredentials(t *testing.T) {
+func TestResolveFromServerNoCredentials(t *testing.T) {
+	t.Setenv(RuntimeDirEnv, t.TempDir())
+
 	withSuppressedLog(func() {
 		ts := &testServer{
 			bootstrapped: make(chan bool, 1),
@@ -115,27 +147,31 @@ func TestPrintResponseNoCredentials(t *testing.T) {
 
 		params := map[string]string{}
 
-		err := PrintResponse(params)
+		_, err := ResolveFromServer(params)
 		if err == nil || err.Error() != "no credentials given" {
 			t.Fatalf("expecting a 'no credentials given' error, got %v", err)
 		}
 	})
 }
 
-func TestPrintFromConfiguration(t *testing.T) {
+func TestFrameFromConfiguration(t *testing.T) {
 	withSuppressedLog(func() {
-		res := capture.All(func() {
-			printFromConfiguration("/path", &ContainerBuildConfig{
-				InstanceData: "instance data",
-				ServerFqdn:   "test.fqdn.com",
-				ServerIP:     "1.1.1.1",
-				Username:     "banjo",
-				Password:     "kazooie",
-				Ca:           "ca",
-			})
+		server := UpdateServer{Fqdn: "test.fqdn.com", IP: "1.1.1.1"}
+		frame := frameFromConfiguration("/path", server, &ContainerBuildConfig{
+			InstanceData: "instance data",
+			ServerFqdn:   "test.fqdn.com",
+			ServerIP:     "1.1.1.1",
+			Username:     "banjo",
+			Password:     "kazooie",
+			Ca:           "ca",
 		})
 
-		lines := strings.Split(string(res.Stdout), "\n")
+		var buf bytes.Buffer
+		if err := WriteFrame(&buf, frame); err != nil {
+			t.Fatalf("Expected error to be nil, got: %v", err)
+		}
+
+		lines := strings.Split(buf.String(), "\n")
 		expected := []string{
 			"RESOLVEDURL",
 			"X-Instance-Data:instance data",
@@ -154,3 +190,80 @@ func TestPrintFromConfiguration(t *testing.T) {
 		}
 	})
 }
+
+func TestResolveUpdateServerOncePerProcess(t *testing.T) {
+	t.Setenv(RuntimeDirEnv, t.TempDir())
+
+	server, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not start test server: %v", err)
+	}
+	defer server.Close()
+
+	probes := make(chan struct{}, 10)
+	go func() {
+		for {
+			conn, err := server.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+			probes <- struct{}{}
+		}
+	}()
+
+	oldPort, oldHostsFile, oldResolved := updateServerPort, hostsFile, resolvedServer
+	defer func() { updateServerPort, hostsFile, resolvedServer = oldPort, oldHostsFile, oldResolved }()
+	_, updateServerPort, _ = net.SplitHostPort(server.Addr().String())
+	hostsFile = filepath.Join(t.TempDir(), "hosts")
+	resolvedServer = nil
+
+	if err := os.WriteFile(hostsFile, nil, 0o644); err != nil {
+		t.Fatalf("could not write the hosts file: %v", err)
+	}
+
+	cfg := &ContainerBuildConfig{
+		Servers: []UpdateServer{{Fqdn: "up.test.lan", IP: "127.0.0.1", Priority: 1}},
+	}
+
+	withSuppressedLog(func() {
+		if _, err := resolveUpdateServer(cfg, true); err != nil {
+			t.Fatalf("should be nil but got: %v", err)
+		}
+		<-probes
+
+		contents, _ := os.ReadFile(hostsFile)
+		if !strings.Contains(string(contents), "127.0.0.1 up.test.lan") {
+			t.Fatalf("The hosts file should point to the selected server, got: %q", contents)
+		}
+
+		// Neither another selection nor the hosts file are needed for the
+		// next repositories resolved by the same process.
+		if err := os.WriteFile(hostsFile, nil, 0o644); err != nil {
+			t.Fatalf("could not write the hosts file: %v", err)
+		}
+		for i := 0; i < 3; i++ {
+			selected, err := resolveUpdateServer(cfg, false)
+			if err != nil || selected.Fqdn != "up.test.lan" {
+				t.Fatalf("Expected 'up.test.lan', got '%v': %v", selected.Fqdn, err)
+			}
+		}
+
+		// A changed configuration selects the server again, but the same
+		// selection keeps the hosts file as it is.
+		if _, err := resolveUpdateServer(cfg, true); err != nil {
+			t.Fatalf("should be nil but got: %v", err)
+		}
+		<-probes
+
+		select {
+		case <-probes:
+			t.Fatal("The update server should only have been probed twice")
+		default:
+		}
+
+		if contents, _ := os.ReadFile(hostsFile); len(contents) != 0 {
+			t.Fatalf("The hosts file should not have been touched, got: %q", contents)
+		}
+	})
+}
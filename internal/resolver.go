@@ -0,0 +1,52 @@
+// Copyright (c) 2026 SUSE LLC. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containersuseconnect
+
+import (
+	"net/url"
+	"strings"
+)
+
+// ResolveRepositoryURL returns the URL of the repository living at the given
+// `repoPath` of the registration server as configured in `data`. The returned
+// URL already contains the given `credentials`, so zypper can use it as is
+// when resolving `plugin:` repository URLs.
+func ResolveRepositoryURL(data SUSEConnectData, credentials Credentials, repoPath string) (*url.URL, error) {
+	if strings.TrimSpace(repoPath) == "" {
+		return nil, loggedError(RepositoryError, "No repository path given to resolve")
+	}
+
+	base, err := url.Parse(data.SccURL)
+	if err != nil {
+		return nil, loggedError(RepositoryError, "Unable to parse registration server URL: %s - %v", data.SccURL, err)
+	}
+
+	if base.Scheme == "" || base.Host == "" {
+		return nil, loggedError(RepositoryError, "Invalid registration server URL: %s", data.SccURL)
+	}
+
+	u := base.JoinPath(repoPath)
+	u.User = url.UserPassword(credentials.Username, credentials.Password)
+
+	// Tell zypper to skip the certificate verification as well if the user
+	// asked so for the registration server.
+	if data.Insecure {
+		params := u.Query()
+		params.Set("ssl_verify", "no")
+		u.RawQuery = params.Encode()
+	}
+
+	return u, nil
+}
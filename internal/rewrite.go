@@ -0,0 +1,218 @@
+// Copyright (c) 2026 SUSE LLC. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containersuseconnect
+
+import (
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// URLRewritesEnv is the environment variable used to specify a custom path
+// for the URL rewrites file.
+const URLRewritesEnv = "CONTAINER_SUSECONNECT_URL_REWRITES"
+
+var defaultURLRewritesPath = "/etc/container-suseconnect/rewrites.conf"
+
+// urlRewrite replaces either the `prefix` or the matches of `regex` on the
+// URLs of the repositories with `replacement`.
+type urlRewrite struct {
+	name        string
+	prefix      string
+	regex       *regexp.Regexp
+	replacement string
+	products    []string
+	stripAuth   bool
+}
+
+// URLRewrites has the rules to rewrite the URLs of the repositories given by
+// the registration server, e.g. to point them to a local mirror. Each section
+// of the file is a rule, like:
+//
+//	[mirror]
+//	prefix=https://updates.suse.com/
+//	replacement=http://mirror.example.com/suse/
+//	products=SLES,sle-module-*
+//	auth=strip
+//
+// A `regex` can be given instead of a `prefix`, in which case the replacement
+// can refer to its groups (e.g. `$1`). The rewrite only applies to the URL
+// without its query, which holds the authentication token of the repository.
+// This token is kept unless `auth` is set to `strip`. The optional `products`
+// restricts the rule to products whose identifier matches one of the given
+// globs. The first matching rule wins.
+type URLRewrites struct {
+	rules []urlRewrite
+	err   error
+}
+
+func (ur *URLRewrites) separator() byte {
+	return '='
+}
+
+func (ur *URLRewrites) locations() []string {
+	if path := strings.TrimSpace(os.Getenv(URLRewritesEnv)); path != "" {
+		return []string{path}
+	}
+
+	return []string{defaultURLRewritesPath}
+}
+
+func (ur *URLRewrites) onLocationsNotFound() bool {
+	return true
+}
+
+func (ur *URLRewrites) setSection(name string) {
+	ur.rules = append(ur.rules, urlRewrite{name: name})
+}
+
+func (ur *URLRewrites) fail(format string, params ...interface{}) {
+	if ur.err == nil {
+		ur.err = loggedError(ConfigurationError, format, params...)
+	}
+}
+
+func (ur *URLRewrites) setValues(key, value string) {
+	if len(ur.rules) == 0 {
+		ur.fail("URL rewrite '%v' outside of any section", key)
+		return
+	}
+
+	rule := &ur.rules[len(ur.rules)-1]
+
+	switch key {
+	case "prefix":
+		rule.prefix = value
+	case "regex":
+		re, err := regexp.Compile(value)
+		if err != nil {
+			ur.fail("Invalid regular expression in URL rewrite '%v': %v", rule.name, err)
+			return
+		}
+		rule.regex = re
+	case "replacement":
+		rule.replacement = value
+	case "products":
+		for _, product := range strings.Split(value, ",") {
+			if product = strings.TrimSpace(product); product != "" {
+				rule.products = append(rule.products, product)
+			}
+		}
+	case "auth":
+		switch value {
+		case "keep":
+			rule.stripAuth = false
+		case "strip":
+			rule.stripAuth = true
+		default:
+			ur.fail("Invalid value '%v' for 'auth' in URL rewrite '%v'", value, rule.name)
+		}
+	default:
+		log.Printf("Warning: Unknown key '%v'", key)
+	}
+}
+
+func (ur *URLRewrites) afterParseCheck() error {
+	for _, rule := range ur.rules {
+		if (rule.prefix == "") == (rule.regex == nil) {
+			ur.fail("URL rewrite '%v' needs either a prefix or a regex", rule.name)
+		}
+	}
+
+	return ur.err
+}
+
+// matchesProduct returns true if the rule applies to the product with the
+// given identifier.
+func (rule *urlRewrite) matchesProduct(identifier string) bool {
+	if len(rule.products) == 0 {
+		return true
+	}
+
+	for _, pattern := range rule.products {
+		if matched, _ := path.Match(pattern, identifier); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// rewrite returns the given location rewritten by this rule, and whether the
+// rule matched at all.
+func (rule *urlRewrite) rewrite(location string) (string, bool) {
+	if rule.regex != nil {
+		if !rule.regex.MatchString(location) {
+			return location, false
+		}
+		return rule.regex.ReplaceAllString(location, rule.replacement), true
+	}
+
+	if !strings.HasPrefix(location, rule.prefix) {
+		return location, false
+	}
+	return rule.replacement + strings.TrimPrefix(location, rule.prefix), true
+}
+
+// rewriteURL returns the given repository URL rewritten by the first
+// matching rule for the product with the given identifier.
+func (ur *URLRewrites) rewriteURL(identifier, repoURL string) string {
+	location, query, _ := strings.Cut(repoURL, "?")
+
+	for _, rule := range ur.rules {
+		if !rule.matchesProduct(identifier) {
+			continue
+		}
+
+		rewritten, ok := rule.rewrite(location)
+		if !ok {
+			continue
+		}
+
+		if rule.stripAuth {
+			if u, err := url.Parse(rewritten); err == nil {
+				u.User = nil
+				rewritten = u.String()
+			}
+		} else if query != "" {
+			rewritten += "?" + query
+		}
+
+		return rewritten
+	}
+
+	return repoURL
+}
+
+// Apply rewrites the URLs of the repositories of the given products,
+// including their extensions.
+func (ur *URLRewrites) Apply(products []Product) {
+	for i := range products {
+		for j := range products[i].Repositories {
+			repo := &products[i].Repositories[j]
+
+			rewritten := ur.rewriteURL(products[i].Identifier, repo.URL)
+			if rewritten != repo.URL {
+				log.Printf("Rewrote the URL of repository %s", repo.Name)
+				repo.URL = rewritten
+			}
+		}
+
+		ur.Apply(products[i].Extensions)
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright (c) 2026 SUSE LLC. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containersuseconnect
+
+import (
+	"log"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// RepositoryRulesEnv is the environment variable used to specify a custom
+// path for the repository rules file.
+const RepositoryRulesEnv = "CONTAINER_SUSECONNECT_REPOSITORY_RULES"
+
+var defaultRepositoryRulesPath = "/etc/container-suseconnect/repositories.conf"
+
+// repositoryRule overrides the attributes of the repositories whose name
+// matches the glob `pattern`. Unset attributes are left untouched.
+type repositoryRule struct {
+	pattern      string
+	priority     *int
+	gpgCheck     *bool
+	keepPackages *bool
+	repoType     string
+	path         string
+}
+
+// RepositoryRules has the local rules overriding the attributes of the
+// repositories given by the registration server. The rules file is split in
+// sections named after a glob matching the names of the repositories, e.g.:
+//
+//	[*-Updates]
+//	priority=105
+//	keeppackages=1
+//
+// Rules are applied in order, so later sections win over earlier ones.
+type RepositoryRules struct {
+	rules []repositoryRule
+	err   error
+}
+
+func (rr *RepositoryRules) separator() byte {
+	return '='
+}
+
+func (rr *RepositoryRules) locations() []string {
+	if path := strings.TrimSpace(os.Getenv(RepositoryRulesEnv)); path != "" {
+		return []string{path}
+	}
+
+	return []string{defaultRepositoryRulesPath}
+}
+
+func (rr *RepositoryRules) onLocationsNotFound() bool {
+	return true
+}
+
+func (rr *RepositoryRules) setSection(name string) {
+	if _, err := path.Match(name, ""); err != nil && rr.err == nil {
+		rr.err = loggedError(ConfigurationError, "Invalid repository pattern '%v': %v", name, err)
+	}
+
+	rr.rules = append(rr.rules, repositoryRule{pattern: name})
+}
+
+// parseBool behaves like strconv.ParseBool, but it records an error for the
+// given key if the value is not valid.
+func (rr *RepositoryRules) parseBool(key, value string) *bool {
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		rr.invalidValue(key, value)
+		return nil
+	}
+
+	return &b
+}
+
+func (rr *RepositoryRules) invalidValue(key, value string) {
+	if rr.err == nil {
+		rr.err = loggedError(ConfigurationError, "Invalid value '%v' for '%v' in the repository rules", value, key)
+	}
+}
+
+func (rr *RepositoryRules) setValues(key, value string) {
+	if len(rr.rules) == 0 {
+		if rr.err == nil {
+			rr.err = loggedError(ConfigurationError, "Repository rule '%v' outside of any section", key)
+		}
+		return
+	}
+
+	rule := &rr.rules[len(rr.rules)-1]
+
+	switch key {
+	case "priority":
+		priority, err := strconv.Atoi(value)
+		if err != nil || priority < 1 {
+			rr.invalidValue(key, value)
+			return
+		}
+		rule.priority = &priority
+	case "gpgcheck":
+		rule.gpgCheck = rr.parseBool(key, value)
+	case "keeppackages":
+		rule.keepPackages = rr.parseBool(key, value)
+	case "type":
+		rule.repoType = value
+	case "path":
+		rule.path = value
+	default:
+		log.Printf("Warning: Unknown key '%v'", key)
+	}
+}
+
+func (rr *RepositoryRules) afterParseCheck() error {
+	return rr.err
+}
+
+// apply overrides the attributes of the given repository with the matching
+// rules.
+func (rr *RepositoryRules) apply(repo *Repository) {
+	for _, rule := range rr.rules {
+		if matched, _ := path.Match(rule.pattern, repo.Name); !matched {
+			continue
+		}
+
+		if rule.priority != nil {
+			repo.Priority = rule.priority
+		}
+		if rule.gpgCheck != nil {
+			repo.GPGCheck = rule.gpgCheck
+		}
+		if rule.keepPackages != nil {
+			repo.KeepPackages = rule.keepPackages
+		}
+		if rule.repoType != "" {
+			repo.Type = rule.repoType
+		}
+		if rule.path != "" {
+			repo.Path = rule.path
+		}
+	}
+}
+
+// Apply overrides the attributes of the repositories of the given products,
+// including their extensions, with the matching rules.
+func (rr *RepositoryRules) Apply(products []Product) {
+	for i := range products {
+		for j := range products[i].Repositories {
+			rr.apply(&products[i].Repositories[j])
+		}
+
+		rr.Apply(products[i].Extensions)
+	}
+}
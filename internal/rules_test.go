@@ -0,0 +1,106 @@
+// Copyright (c) 2026 SUSE LLC. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containersuseconnect
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRepositoryRulesNotFound(t *testing.T) {
+	t.Setenv(RepositoryRulesEnv, "/does/not/exist")
+
+	rules := RepositoryRules{}
+	if err := ReadConfiguration(&rules); err != nil {
+		t.Fatalf("A missing rules file is not an error: %v", err)
+	}
+	if len(rules.rules) != 0 {
+		t.Fatalf("Unexpected rules: %v", rules.rules)
+	}
+}
+
+func TestRepositoryRulesApply(t *testing.T) {
+	t.Setenv(RepositoryRulesEnv, "testdata/repositories.conf")
+
+	rules := RepositoryRules{}
+	if err := ReadConfiguration(&rules); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	const expectedOutput = `# generated by container-suseconnect
+
+[SLE-Product-SLES15-Pool]
+name=SLE-Product-SLES15-Pool for sle-15-x86_64
+baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Products/SLE-Product-SLES/15/x86_64/product/?credentials=SMT-http_smt-ec2_susecloud_net
+autorefresh=0
+enabled=1
+
+[SLE15-Installer-Updates]
+name=SLE15-Installer-Updates for sle-15-x86_64
+baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Updates/SLE-INSTALLER/15/x86_64/update/?credentials=SMT-http_smt-ec2_susecloud_net
+autorefresh=1
+enabled=0
+priority=90
+gpgcheck=0
+keeppackages=1
+
+`
+
+	testServiceOutput(t, "testdata/products-sle15.json", expectedOutput,
+		func(buffer *bytes.Buffer, products []Product) {
+			rules.Apply(products)
+
+			// Only dump the first two repositories of the base product.
+			product := products[0]
+			product.Repositories = product.Repositories[:2]
+			product.Extensions = nil
+			DumpProducts(buffer, []Product{product})
+		})
+}
+
+func TestRepositoryRulesInvalid(t *testing.T) {
+	cases := map[string]string{
+		"priority=10\n":               "outside of any section",
+		"[*]\npriority=high\n":        "Invalid value 'high' for 'priority'",
+		"[*]\ngpgcheck=maybe\n":       "Invalid value 'maybe' for 'gpgcheck'",
+		"[[]\nkeeppackages=1\n":       "Invalid repository pattern",
+		"[*]\npriority=0\ntype=rpm\n": "Invalid value '0' for 'priority'",
+	}
+
+	for contents, msg := range cases {
+		rules := RepositoryRules{}
+		err := parse(&rules, strings.NewReader(contents))
+		if err == nil || !strings.Contains(err.Error(), msg) {
+			t.Fatalf("Expected an error containing '%v', got '%v'", msg, err)
+		}
+	}
+}
+
+func TestRepositoryAttributesFromServer(t *testing.T) {
+	products, err := parseProducts(strings.NewReader(`[{"repositories": [
+		{"name": "repo", "url": "https://smt.test.lan/repo", "priority": 80, "type": "plaindir"}
+	]}]`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	buffer := bytes.Buffer{}
+	DumpProducts(&buffer, []Product{products[0]})
+
+	if !strings.Contains(buffer.String(), "enabled=0\npriority=80\ntype=plaindir\n") {
+		t.Fatalf("Unexpected output:\n%v", buffer.String())
+	}
+}
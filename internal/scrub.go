@@ -0,0 +1,186 @@
+// Copyright (c) 2026 SUSE LLC. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containersuseconnect
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ServiceName is the name of the zypper service plugin, which zypper stores
+// along with each of the repositories of the service.
+const ServiceName = "container-suseconnect-zypp"
+
+// maxScannedFileSize is the size above which files are not scanned for
+// credentials material.
+const maxScannedFileSize = 4 << 20
+
+var (
+	reposDir       = "/etc/zypp/repos.d"
+	credentialsDir = "/etc/zypp/credentials.d"
+	mountInfoFile  = "/proc/self/mountinfo"
+
+	// sccUsernameRe matches the username of system credentials from SCC.
+	sccUsernameRe = regexp.MustCompile(`SCC_[0-9a-fA-F]{32}`)
+)
+
+// RemoveLogFile removes the log file, unless it is not a regular file.
+func RemoveLogFile() error {
+	path := getLogPath()
+
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	return os.Remove(path)
+}
+
+// serviceRepository returns true if the given repository file was added by
+// zypper for the container-suseconnect service. These files contain the URLs
+// of the repositories, including their authentication tokens.
+func serviceRepository(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if strings.TrimSpace(scanner.Text()) == "service="+ServiceName {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RemoveServiceRepositories removes the repository files that zypper stored
+// for the container-suseconnect service. They are written again by zypper on
+// the next refresh of the service.
+func RemoveServiceRepositories() error {
+	paths, err := filepath.Glob(filepath.Join(reposDir, "*.repo"))
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		if serviceRepository(path) {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// mountPoints returns the mount points of the current process other than the
+// root directory, which are not part of the image (e.g. /proc or build
+// secrets).
+func mountPoints() []string {
+	file, err := os.Open(mountInfoFile)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	unescape := strings.NewReplacer(`\040`, " ", `\011`, "\t", `\012`, "\n", `\134`, `\`)
+
+	var mounts []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 || fields[4] == "/" {
+			continue
+		}
+		mounts = append(mounts, unescape.Replace(fields[4]))
+	}
+
+	return mounts
+}
+
+// leftoverReason returns why the given file holds credentials material, or
+// an empty string if it does not.
+func leftoverReason(root, path string) string {
+	if filepath.Dir(path) == filepath.Join(root, credentialsDir) {
+		return "zypp credentials file"
+	}
+
+	if filepath.Dir(path) == filepath.Join(root, reposDir) && serviceRepository(path) {
+		return fmt.Sprintf("repository of the %s service", ServiceName)
+	}
+
+	data, err := os.ReadFile(path)
+	if err == nil && sccUsernameRe.Match(data) {
+		return "SCC system credentials"
+	}
+
+	return ""
+}
+
+// FindLeftovers walks the filesystem below root and returns a description of
+// each file which still holds credentials material, like zypp credentials
+// files or SCC system credentials. Mount points are not scanned, since they
+// are not part of the image.
+func FindLeftovers(root string) ([]string, error) {
+	skip := map[string]bool{}
+	for _, mount := range mountPoints() {
+		skip[mount] = true
+	}
+
+	var leftovers []string
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			// Unreadable entries cannot be checked, but they should not
+			// abort the scan either.
+			return nil
+		}
+
+		if entry.IsDir() {
+			if path != root && skip[path] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !entry.Type().IsRegular() || skip[path] {
+			return nil
+		}
+		if info, err := entry.Info(); err != nil || info.Size() > maxScannedFileSize {
+			return nil
+		}
+
+		if reason := leftoverReason(root, path); reason != "" {
+			leftovers = append(leftovers, fmt.Sprintf("%s: %s", path, reason))
+		}
+
+		return nil
+	})
+
+	return leftovers, err
+}
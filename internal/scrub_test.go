@@ -0,0 +1,124 @@
+// Copyright (c) 2026 SUSE LLC. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containersuseconnect
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// writeScrubFiles writes the given files below root.
+func writeScrubFiles(t *testing.T, root string, files map[string]string) {
+	for name, contents := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("Could not create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			t.Fatalf("Could not write file: %v", err)
+		}
+	}
+}
+
+func TestFindLeftovers(t *testing.T) {
+	root := t.TempDir()
+	writeScrubFiles(t, root, map[string]string{
+		"etc/zypp/credentials.d/SCCcredentials": "username=foo\npassword=bar\n",
+		"etc/zypp/repos.d/service.repo":         "[repo]\nbaseurl=https://updates.suse.com/?token\nservice=" + ServiceName + "\n",
+		"etc/zypp/repos.d/other.repo":           "[repo]\nbaseurl=https://download.opensuse.org\n",
+		"var/log/build.log":                     "user SCC_0123456789abcdef0123456789abcdef\n",
+		"usr/share/doc/README":                  "nothing to see here\n",
+		"mnt/secret/SCCcredentials":             "username=SCC_0123456789abcdef0123456789abcdef\n",
+	})
+
+	old := mountInfoFile
+	defer func() { mountInfoFile = old }()
+
+	mountInfoFile = filepath.Join(t.TempDir(), "mountinfo")
+	mountinfo := "22 1 0:21 / / rw - overlay overlay rw\n" +
+		"23 22 0:22 / " + filepath.Join(root, "mnt", "secret") + " ro - tmpfs tmpfs ro\n"
+	if err := os.WriteFile(mountInfoFile, []byte(mountinfo), 0o644); err != nil {
+		t.Fatalf("Could not write file: %v", err)
+	}
+
+	leftovers, err := FindLeftovers(root)
+	if err != nil {
+		t.Fatalf("Expected error to be nil: %v", err)
+	}
+	sort.Strings(leftovers)
+
+	expected := []string{
+		"etc/zypp/credentials.d/SCCcredentials: zypp credentials file",
+		"etc/zypp/repos.d/service.repo: repository of the " + ServiceName + " service",
+		"var/log/build.log: SCC system credentials",
+	}
+	if len(leftovers) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, leftovers)
+	}
+	for i, leftover := range leftovers {
+		if !strings.HasSuffix(leftover, expected[i]) {
+			t.Fatalf("Expected '%v', got '%v'", expected[i], leftover)
+		}
+	}
+}
+
+func TestRemoveServiceRepositories(t *testing.T) {
+	old := reposDir
+	defer func() { reposDir = old }()
+
+	reposDir = t.TempDir()
+	writeScrubFiles(t, reposDir, map[string]string{
+		"service.repo": "[repo]\nservice=" + ServiceName + "\n",
+		"other.repo":   "[repo]\nbaseurl=https://download.opensuse.org\n",
+	})
+
+	if err := RemoveServiceRepositories(); err != nil {
+		t.Fatalf("Expected error to be nil: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(reposDir, "service.repo")); !os.IsNotExist(err) {
+		t.Fatalf("The repository of the service should have been removed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(reposDir, "other.repo")); err != nil {
+		t.Fatalf("Other repositories should be kept: %v", err)
+	}
+}
+
+func TestRemoveLogFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "suseconnect.log")
+	t.Setenv(LogEnv, path)
+
+	if err := RemoveLogFile(); err != nil {
+		t.Fatalf("A missing log file is not an error: %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte("log"), 0o640); err != nil {
+		t.Fatalf("Could not write file: %v", err)
+	}
+	if err := RemoveLogFile(); err != nil {
+		t.Fatalf("Expected error to be nil: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("The log file should have been removed: %v", err)
+	}
+
+	t.Setenv(LogEnv, "/dev/null")
+	if err := RemoveLogFile(); err != nil {
+		t.Fatalf("Expected error to be nil: %v", err)
+	}
+}
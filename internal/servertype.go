@@ -0,0 +1,239 @@
+// Copyright (c) 2026 SUSE LLC. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containersuseconnect
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/SUSE/container-suseconnect/internal/regionsrv"
+)
+
+// ServerType is the kind of registration server, which determines the
+// endpoints used to request the products and how their repositories
+// authenticate.
+type ServerType string
+
+const (
+	// ServerTypeUnknown is used when the type could not be detected, in
+	// which case it is inferred from the responses of the server.
+	ServerTypeUnknown ServerType = ""
+
+	// ServerTypeSCC is the SUSE Customer Center.
+	ServerTypeSCC ServerType = "scc"
+
+	// ServerTypeRMT is a Repository Mirroring Tool server, including the
+	// update servers of the public cloud.
+	ServerTypeRMT ServerType = "rmt"
+
+	// ServerTypeSMT is a Subscription Management Tool server.
+	ServerTypeSMT ServerType = "smt"
+
+	// ServerTypeSUSEManager is a SUSE Manager server or proxy.
+	ServerTypeSUSEManager ServerType = "suse-manager"
+)
+
+// serverTypeFileName is the name of the file in the runtime directory which
+// caches the detected type of the registration server, so it is not probed
+// on every run of the zypper plugin.
+const serverTypeFileName = "server-type"
+
+// maxProbeResponseSize is the size of the responses read when probing the
+// type of the registration server.
+const maxProbeResponseSize = 64 << 10
+
+// serverTypeProbe is a request which is only answered by a given type of
+// registration server, along with the check of its response.
+type serverTypeProbe struct {
+	path       string
+	query      string
+	serverType ServerType
+	matches    func(body []byte) bool
+}
+
+// jsonWithKey returns a check for JSON objects having the given key.
+func jsonWithKey(key string) func([]byte) bool {
+	return func(body []byte) bool {
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(body, &object); err != nil {
+			return false
+		}
+
+		_, ok := object[key]
+		return ok
+	}
+}
+
+// serverTypeProbes are the requests which are only answered by a given type of
+// registration server, in the order in which they are probed. RMT comes first
+// since it is the most common one, including the update servers of the public
+// cloud.
+var serverTypeProbes = []serverTypeProbe{
+	{
+		path:       "/api/health/status",
+		serverType: ServerTypeRMT,
+		matches:    jsonWithKey("state"),
+	},
+	{
+		path:       "/rhn/manager/api/api/getVersion",
+		serverType: ServerTypeSUSEManager,
+		matches:    jsonWithKey("success"),
+	},
+	{
+		path:       "/center/regsvc",
+		query:      "command=listproducts&lang=en-US&version=1.0",
+		serverType: ServerTypeSMT,
+		matches: func(body []byte) bool {
+			return bytes.Contains(body, []byte("<productlist"))
+		},
+	},
+}
+
+// parseServerType returns the server type with the given name.
+func parseServerType(name string) (ServerType, bool) {
+	switch serverType := ServerType(name); serverType {
+	case ServerTypeSCC, ServerTypeRMT, ServerTypeSMT, ServerTypeSUSEManager:
+		return serverType, true
+	}
+
+	return ServerTypeUnknown, false
+}
+
+func (serverType ServerType) String() string {
+	if serverType == ServerTypeUnknown {
+		return "unknown"
+	}
+
+	return string(serverType)
+}
+
+// usesSystemProducts returns true if the products are requested from this
+// type of server with the system credentials, since it does not provide the
+// registration codes of the subscriptions.
+func (serverType ServerType) usesSystemProducts() bool {
+	return serverType == ServerTypeRMT || serverType == ServerTypeSMT || serverType == ServerTypeSUSEManager
+}
+
+// needsCredentialsFile returns true if the repositories given by this type of
+// server authenticate with a zypp credentials file, but do not reference it.
+func (serverType ServerType) needsCredentialsFile() bool {
+	return serverType == ServerTypeRMT || serverType == ServerTypeSMT || serverType == ServerTypeSUSEManager
+}
+
+// isSCCHost returns true if the given URL points to SCC.
+func isSCCHost(rawURL string) bool {
+	sccURL, _ := url.Parse(sccURLStr)
+	u, err := url.Parse(rawURL)
+
+	return err == nil && u.Hostname() == sccURL.Hostname()
+}
+
+// cachedServerType returns the type of the registration server at the given
+// URL as detected by a previous run, if any.
+func cachedServerType(serverURL string) (ServerType, bool) {
+	data, err := regionsrv.ReadRuntimeFile(serverTypeFileName)
+	if err != nil {
+		return ServerTypeUnknown, false
+	}
+
+	cachedURL, name, _ := strings.Cut(strings.TrimSpace(string(data)), " ")
+	if cachedURL != serverURL {
+		return ServerTypeUnknown, false
+	}
+
+	return parseServerType(name)
+}
+
+// RemoveCachedServerType removes the cached type of the registration server,
+// if it exists.
+func RemoveCachedServerType() error {
+	return regionsrv.RemoveRuntimeFile(serverTypeFileName)
+}
+
+// probeServerType returns true if the registration server given by `data`
+// answers the given probe as expected.
+func probeServerType(client *http.Client, data SUSEConnectData, probe serverTypeProbe) (bool, error) {
+	req, err := http.NewRequest("GET", data.SccURL, nil)
+	if err != nil {
+		return false, err
+	}
+	req.URL.Path = probe.path
+	req.URL.RawQuery = probe.query
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxProbeResponseSize))
+	if err != nil {
+		return false, err
+	}
+
+	return probe.matches(body), nil
+}
+
+// DetectServerType returns the type of the registration server given by
+// `data`. The `server_type` given in the SUSEConnect file always wins.
+// Otherwise, the server is probed with requests which are only answered by a
+// given type of server, checking the content of the responses. Redirects are
+// not followed, since they usually lead to some generic page. The detected
+// type is cached in the runtime directory, so the server is only probed
+// once. If none of the probes match, or all of them fail, ServerTypeUnknown
+// is returned.
+func DetectServerType(data SUSEConnectData) ServerType {
+	if data.ServerType != ServerTypeUnknown {
+		return data.ServerType
+	}
+
+	if isSCCHost(data.SccURL) {
+		return ServerTypeSCC
+	}
+
+	if serverType, ok := cachedServerType(data.SccURL); ok {
+		return serverType
+	}
+
+	client := newHTTPClient(data)
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	for _, probe := range serverTypeProbes {
+		// A failed probe does not rule out the other types of server.
+		matches, err := probeServerType(client, data, probe)
+		if err != nil {
+			log.Printf("Could not probe the registration server for %v: %v", probe.serverType, err)
+			continue
+		}
+
+		if matches {
+			regionsrv.WriteRuntimeFile(serverTypeFileName, []byte(data.SccURL+" "+string(probe.serverType)+"\n"))
+			return probe.serverType
+		}
+	}
+
+	return ServerTypeUnknown
+}
@@ -0,0 +1,222 @@
+// Copyright (c) 2026 SUSE LLC. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containersuseconnect
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/SUSE/container-suseconnect/internal/regionsrv"
+)
+
+func TestServerTypeFromSUSEConnect(t *testing.T) {
+	data := &SUSEConnectData{}
+	if err := parse(data, strings.NewReader("url: https://rmt.example.com\nserver_type: suse-manager\n")); err != nil {
+		t.Fatalf("Could not parse: %v", err)
+	}
+	if data.ServerType != ServerTypeSUSEManager {
+		t.Fatalf("Unexpected server type '%v'", data.ServerType)
+	}
+	if DetectServerType(*data) != ServerTypeSUSEManager {
+		t.Fatal("The server type from the SUSEConnect file should win")
+	}
+
+	prepareLogger()
+	data = &SUSEConnectData{}
+	err := parse(data, strings.NewReader("server_type: spacewalk\n"))
+	if err == nil || !strings.Contains(err.Error(), "Unknown server type 'spacewalk'") {
+		t.Fatalf("Expected an error for an unknown server type, got: %v", err)
+	}
+}
+
+func TestDetectServerType(t *testing.T) {
+	t.Setenv(regionsrv.RuntimeDirEnv, t.TempDir())
+
+	if DetectServerType(SUSEConnectData{SccURL: sccURLStr}) != ServerTypeSCC {
+		t.Fatal("SCC should be detected by its hostname")
+	}
+
+	cases := []struct {
+		path       string
+		status     int
+		body       string
+		serverType ServerType
+	}{
+		{"/rhn/manager/api/api/getVersion", http.StatusOK, `{"success": true, "result": "4.3"}`, ServerTypeSUSEManager},
+		{"/api/health/status", http.StatusOK, `{"state": "online"}`, ServerTypeRMT},
+		{"/center/regsvc", http.StatusOK, `<?xml version="1.0"?><productlist lang="en"/>`, ServerTypeSMT},
+		{"/center/regsvc", http.StatusInternalServerError, "", ServerTypeUnknown},
+		{"/api/health/status", http.StatusOK, "<html>Welcome</html>", ServerTypeUnknown},
+		{"/api/health/status", http.StatusUnauthorized, `{"state": "online"}`, ServerTypeUnknown},
+		{"/", http.StatusOK, `{"state": "online", "success": true}`, ServerTypeUnknown},
+	}
+
+	for _, c := range cases {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != c.path {
+				http.NotFound(w, r)
+				return
+			}
+			w.WriteHeader(c.status)
+			fmt.Fprint(w, c.body)
+		}))
+
+		serverType := DetectServerType(SUSEConnectData{SccURL: ts.URL, Insecure: true})
+		ts.Close()
+
+		if serverType != c.serverType {
+			t.Fatalf("Expected '%v' for %v (%d), got '%v'", c.serverType, c.path, c.status, serverType)
+		}
+	}
+}
+
+func TestDetectServerTypeRedirect(t *testing.T) {
+	t.Setenv(regionsrv.RuntimeDirEnv, t.TempDir())
+
+	// Every path is redirected to a page which would match all the probes.
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/login" {
+			http.Redirect(w, r, "/login", http.StatusFound)
+			return
+		}
+		fmt.Fprint(w, `{"state": "online", "success": true}`)
+	}))
+	defer ts.Close()
+
+	if serverType := DetectServerType(SUSEConnectData{SccURL: ts.URL, Insecure: true}); serverType != ServerTypeUnknown {
+		t.Fatalf("Redirects should not be followed, got '%v'", serverType)
+	}
+}
+
+func TestDetectServerTypeFailedProbe(t *testing.T) {
+	t.Setenv(regionsrv.RuntimeDirEnv, t.TempDir())
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/health/status":
+			// Reset the connection without any response.
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				t.Errorf("Could not hijack the connection: %v", err)
+				return
+			}
+			conn.(*net.TCPConn).SetLinger(0)
+			conn.Close()
+		case "/rhn/manager/api/api/getVersion":
+			fmt.Fprint(w, `{"success": true, "result": "4.3"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	prepareLogger()
+	if serverType := DetectServerType(SUSEConnectData{SccURL: ts.URL, Insecure: true}); serverType != ServerTypeSUSEManager {
+		t.Fatalf("The next probes should still be tried, got '%v'", serverType)
+	}
+	if !strings.Contains(logged.String(), "Could not probe the registration server for rmt: ") {
+		t.Fatalf("Expected the failed probe to be logged, got: %v", logged.String())
+	}
+}
+
+func TestDetectServerTypeCached(t *testing.T) {
+	t.Setenv(regionsrv.RuntimeDirEnv, t.TempDir())
+
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/rhn/manager/api/api/getVersion" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"success": true, "result": "4.3"}`)
+	}))
+	defer ts.Close()
+
+	data := SUSEConnectData{SccURL: ts.URL, Insecure: true}
+	for i := 0; i < 3; i++ {
+		if serverType := DetectServerType(data); serverType != ServerTypeSUSEManager {
+			t.Fatalf("Expected '%v', got '%v'", ServerTypeSUSEManager, serverType)
+		}
+	}
+	if requests != 2 {
+		t.Fatalf("The server should only have been probed once, got %d requests", requests)
+	}
+
+	if err := RemoveCachedServerType(); err != nil {
+		t.Fatalf("Could not remove the cached server type: %v", err)
+	}
+	DetectServerType(data)
+	if requests != 4 {
+		t.Fatalf("The server should have been probed again, got %d requests", requests)
+	}
+}
+
+func TestRequestProductsFromRMT(t *testing.T) {
+	// We setup a fake http server that mocks a registration server.
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/connect/systems/products" {
+			t.Errorf("Unexpected request to %v", r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+
+		fmt.Fprint(w, `[{"identifier": "SLES", "repositories": [
+			{"name": "SLE-Product-SLES15-SP5-Updates",
+			 "url": "https://rmt.example.com/repo/SUSE/Updates/SLE-Product-SLES/15-SP5/x86_64/update"}]}]`)
+	}))
+	defer ts.Close()
+
+	var cr Credentials
+	var ip InstalledProduct
+	data := SUSEConnectData{SccURL: ts.URL, Insecure: true, ServerType: ServerTypeRMT}
+
+	products, err := RequestProducts(data, cr, ip)
+	if err != nil {
+		t.Fatalf("It should've run just fine: %v", err)
+	}
+
+	expectedURL := "https://rmt.example.com/repo/SUSE/Updates/SLE-Product-SLES/15-SP5/x86_64/update?credentials=SCCcredentials"
+	if len(products) != 1 || products[0].Repositories[0].URL != expectedURL {
+		t.Fatalf("Unexpected products: %+v", products)
+	}
+
+	// SMT servers reference the credentials files of SUSEConnect, but only
+	// for some of their repositories.
+	data.ServerType = ServerTypeSMT
+	smt := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"identifier": "SLES", "repositories": [
+			{"name": "SLES15-SP5-Updates", "url": "https://smt.example.com/repo/SLES15-SP5-Updates?credentials=SMT-http_smt_example_com"},
+			{"name": "SLES15-SP5-Pool", "url": "https://smt.example.com/repo/SLES15-SP5-Pool"}]}]`)
+	}))
+	defer smt.Close()
+	data.SccURL = smt.URL
+
+	if products, err = RequestProducts(data, cr, ip); err != nil {
+		t.Fatalf("It should've run just fine: %v", err)
+	}
+	for _, repo := range products[0].Repositories {
+		if !strings.Contains(repo.URL, "?credentials=") {
+			t.Fatalf("The repositories of SMT should reference a credentials file: %v", repo.URL)
+		}
+	}
+	if !strings.HasSuffix(products[0].Repositories[0].URL, "credentials=SMT-http_smt_example_com") {
+		t.Fatalf("Existing references should be kept: %v", products[0].Repositories[0].URL)
+	}
+}
@@ -15,11 +15,16 @@
 package containersuseconnect
 
 import (
+	"encoding/xml"
 	"fmt"
 	"io"
+	"log"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -31,59 +36,176 @@ func boolToInt(b bool) int {
 	return 0
 }
 
-// DumpRepositories dumps the repositories of the given product to the given
-// writer.
-func DumpRepositories(w io.Writer, product Product) {
+// MinimalEnv is the environment variable used to skip the recommended
+// modules, so only the base product and the modules explicitly requested or
+// installed are dumped.
+const MinimalEnv = "CONTAINER_SUSECONNECT_MINIMAL"
+
+// minimalMode returns true if the recommended modules have to be skipped.
+func minimalMode() bool {
+	minimal, err := strconv.ParseBool(os.Getenv(MinimalEnv))
+	return err == nil && minimal
+}
+
+// DumpProducts dumps the repositories of all the given products to the given
+// writer. Repositories shared by several products are only printed once, and
+// they are sorted by name, so the output does not depend on the order given
+// by the registration server. Repositories whose alias is already used in the
+// image are reported.
+func DumpProducts(w io.Writer, products []Product) {
 	fmt.Fprintf(w, "# generated by container-suseconnect\n")
 	fmt.Fprintf(w, "\n")
 
-	// Always print the first product disregarding if it is recommended or not
-	dumpRepositoriesRecursive(w, product, true)
+	dropIns := readModuleDropIns()
+
+	var repos []Repository
+	for _, product := range products {
+		// Always print the base products disregarding if they are
+		// recommended or not
+		repos = append(repos, selectRepositories(product, true, dropIns)...)
+	}
+
+	repos = mergeRepositories(nil, repos)
+	sort.Slice(repos, func(i, j int) bool {
+		return repos[i].Name < repos[j].Name
+	})
+	reportAliasCollisions(repos)
+
+	for _, repo := range repos {
+		dumpRepository(w, repo)
+	}
 }
 
-// dumpRepositoriesRecursive prints available product repositories to the
-// provided writer.
+// selectRepositories returns the repositories of the given product which
+// have to be printed.
 //
 // The function takes all product extensions into account, which will be
-// printed recursively too.
+// selected recursively too.
 //
 // `dumpAlways` specifies if the products repositories should be
-// printed ignoring if it is recommended or not.
+// selected ignoring if it is recommended or not. Recommended products are
+// not selected in minimal mode.
 //
 // The user has the option to enable certain modules via its `identifier` by
-// setting them within the `ADDITIONAL_MODULES` environment variable. Multiple
-// modules can be set comma separated.
-func dumpRepositoriesRecursive(
-	w io.Writer,
-	product Product,
-	dumpAlways bool,
-) {
-	for _, repo := range product.Repositories {
-		if product.Recommended || dumpAlways ||
-			moduleEnabledInEnv(product.Identifier) ||
-			moduleEnabledInProductFiles(product.Identifier) {
-
-			fmt.Fprintf(w, "[%s]\n", repo.Name)
-			fmt.Fprintf(w, "name=%s\n", repo.Description)
-			fmt.Fprintf(w, "baseurl=%s\n", repo.URL)
-			fmt.Fprintf(w, "autorefresh=%d\n", boolToInt(repo.Autorefresh))
-			fmt.Fprintf(w, "enabled=%d\n", boolToInt(repo.Enabled))
-			fmt.Fprintf(w, "\n")
+// setting them within the `ADDITIONAL_MODULES` environment variable, or in
+// the drop-in files of the image, which are given as `dropIns` so they are
+// only read once for all the products. Multiple modules can be set comma
+// separated. The state of the selected repositories can then be changed with
+// the `ENABLE_REPOSITORIES` and `DISABLE_REPOSITORIES` environment variables.
+// Products blocked by the policy are never selected, along with their
+// extensions, and neither are the repositories blocked by it.
+func selectRepositories(product Product, dumpAlways bool, dropIns map[string]bool) []Repository {
+	var repos []Repository
+
+	selected := (product.Recommended && !minimalMode()) || dumpAlways ||
+		moduleEnabledInEnv(product.Identifier) ||
+		dropIns[product.Identifier] ||
+		moduleEnabledInProductFiles(product)
+
+	// Blocked products are skipped along with their extensions, but they are
+	// only reported when they would have been selected.
+	if selected && !activePolicy.productPermitted(product) {
+		return repos
+	} else if !selected && activePolicy.blockedBy("product", product.Identifier) != "" {
+		return repos
+	}
+
+	if selected {
+		for _, repo := range product.Repositories {
+			if !activePolicy.repositoryPermitted(repo) {
+				continue
+			}
+
+			repo.Enabled = repositoryEnabled(repo)
+			repos = append(repos, repo)
 		}
 	}
 
 	// Continue the traversal for the extensions if needed
-	if len(product.Extensions) > 0 {
-		for _, extension := range product.Extensions {
-			dumpRepositoriesRecursive(w, extension, false)
+	for _, extension := range product.Extensions {
+		repos = append(repos, selectRepositories(extension, false, dropIns)...)
+	}
+
+	return repos
+}
+
+// dumpRepository prints the given repository to the provided writer, under its
+// alias.
+func dumpRepository(w io.Writer, repo Repository) {
+	fmt.Fprintf(w, "[%s]\n", repositoryAlias(repo.Name))
+	fmt.Fprintf(w, "name=%s\n", repo.Description)
+	fmt.Fprintf(w, "baseurl=%s\n", repo.URL)
+	fmt.Fprintf(w, "autorefresh=%d\n", boolToInt(repo.Autorefresh))
+	fmt.Fprintf(w, "enabled=%d\n", boolToInt(repo.Enabled))
+	dumpOptionalAttributes(w, repo)
+	fmt.Fprintf(w, "\n")
+}
+
+// dumpOptionalAttributes prints the attributes of the given repository which
+// are only set by some registration servers or by the repository rules.
+func dumpOptionalAttributes(w io.Writer, repo Repository) {
+	if repo.Priority != nil {
+		fmt.Fprintf(w, "priority=%d\n", *repo.Priority)
+	}
+	if repo.GPGCheck != nil {
+		fmt.Fprintf(w, "gpgcheck=%d\n", boolToInt(*repo.GPGCheck))
+	}
+	if repo.KeepPackages != nil {
+		fmt.Fprintf(w, "keeppackages=%d\n", boolToInt(*repo.KeepPackages))
+	}
+	if repo.Type != "" {
+		fmt.Fprintf(w, "type=%s\n", repo.Type)
+	}
+	if repo.Path != "" {
+		fmt.Fprintf(w, "path=%s\n", repo.Path)
+	}
+}
+
+// patternsFromEnv returns the comma-separated patterns given by the
+// environment variable with the given name.
+func patternsFromEnv(name string) []string {
+	var patterns []string
+
+	for _, pattern := range strings.Split(os.Getenv(name), ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+
+	return patterns
+}
+
+// matchesAnyPattern returns true if the given name matches any of the given
+// glob patterns.
+func matchesAnyPattern(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
 		}
 	}
+
+	return false
+}
+
+// repositoryEnabled returns whether the given repository is enabled. The
+// state given by the registration server can be overridden with the glob
+// patterns on repository names from the `ENABLE_REPOSITORIES` and the
+// `DISABLE_REPOSITORIES` environment variables, where disabling wins.
+func repositoryEnabled(repo Repository) bool {
+	if matchesAnyPattern(patternsFromEnv("DISABLE_REPOSITORIES"), repo.Name) {
+		return false
+	}
+	if matchesAnyPattern(patternsFromEnv("ENABLE_REPOSITORIES"), repo.Name) {
+		return true
+	}
+
+	return repo.Enabled
 }
 
 // moduleEnabledInEnv returns true if the provided `identifier` is included in
 // the `ADDITIONAL_MODULES` environment variable, otherwise false.
 func moduleEnabledInEnv(identifier string) bool {
-	for _, i := range strings.Split(os.Getenv("ADDITIONAL_MODULES"), ",") {
+	for _, i := range patternsFromEnv("ADDITIONAL_MODULES") {
 		if identifier == i {
 			return true
 		}
@@ -92,35 +214,53 @@ func moduleEnabledInEnv(identifier string) bool {
 	return false
 }
 
-// moduleEnabledInProductFiles returns true if the provided `identifier` is
-// a name of a file in the  /etc/product.d/*.prod, otherwise false.
-func moduleEnabledInProductFiles(identifier string) bool {
-	files, err := os.ReadDir("/etc/products.d")
+var productsDir = "/etc/products.d"
+
+// moduleEnabledInProductFiles returns true if the identifier of the provided
+// `product` is a name of a file in the /etc/products.d/*.prod, and the version
+// and architecture of this file match the ones of the product, otherwise
+// false. Mismatched files (e.g. left behind by an older service pack) are
+// ignored with a warning.
+func moduleEnabledInProductFiles(product Product) bool {
+	if strings.ContainsRune(product.Identifier, filepath.Separator) {
+		return false
+	}
+
+	path := filepath.Join(productsDir, product.Identifier+".prod")
+	info, err := os.Lstat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+
+	data, err := os.ReadFile(path)
 	if err != nil {
 		return false
 	}
 
-	for _, file := range files {
-		ext := filepath.Ext(file.Name())
-		info, err := file.Info()
-		if err != nil {
-			continue
-		}
+	var installed InstalledProduct
+	if err := xml.Unmarshal(data, &installed); err != nil {
+		log.Printf("Warning: ignoring %v, it can't be parsed: %v", path, err)
+		return false
+	}
 
-		if ext == ".prod" &&
-			identifier == strings.TrimSuffix(file.Name(), ext) &&
-			info.Mode()&os.ModeSymlink == 0 {
-			return true
-		}
+	if installed.Version != product.Version || installed.Arch != product.Arch {
+		log.Printf("Warning: ignoring %v, it is for %v-%v but the registration server provides %v-%v",
+			path, installed.Version, installed.Arch, product.Version, product.Arch)
+		return false
 	}
 
-	return false
+	return true
 }
 
 // ListModules prints the provided `products` slice the provided writer `w` in a
-// human readable way
+// human readable way. Modules blocked by the policy are not listed, along with
+// their extensions.
 func ListModules(w io.Writer, products []Product) {
 	for _, product := range products {
+		if !activePolicy.productPermitted(product) {
+			continue
+		}
+
 		if product.ProductType == "module" {
 			fmt.Fprintf(w, "Name: %v\n", product.Name)
 			fmt.Fprintf(w, "Identifier: %v\n", product.Identifier)
@@ -151,7 +291,7 @@ func ListProducts(w io.Writer, products []Product, baseProduct string) {
 		fmt.Fprintf(w, "Repositories:\n")
 		for idx, repo := range product.Repositories {
 			repoState := "disabled"
-			if repo.Enabled {
+			if repositoryEnabled(repo) {
 				repoState = "enabled"
 			}
 			fmt.Fprintf(w, "%v. %v: %v (%v)\n", idx+1, repo.Name, repo.URL, repoState)
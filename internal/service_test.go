@@ -19,6 +19,8 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -62,11 +64,11 @@ func testServiceOutput(
 func TestServiceOutputSLE12(t *testing.T) {
 	const expectedOutput = `# generated by container-suseconnect
 
-[SLES12-Updates]
-name=SLES12-Updates for sle-12-x86_64
-baseurl=https://smt.test.lan/repo/SUSE/Updates/SLE-SERVER/12/x86_64/update
-autorefresh=1
-enabled=1
+[SLES12-Debuginfo-Pool]
+name=SLES12-Debuginfo-Pool for sle-12-x86_64
+baseurl=https://smt.test.lan/repo/SUSE/Products/SLE-SERVER/12/x86_64/product_debug
+autorefresh=0
+enabled=0
 
 [SLES12-Debuginfo-Updates]
 name=SLES12-Debuginfo-Updates for sle-12-x86_64
@@ -80,67 +82,85 @@ baseurl=https://smt.test.lan/repo/SUSE/Products/SLE-SERVER/12/x86_64/product
 autorefresh=0
 enabled=1
 
-[SLES12-Debuginfo-Pool]
-name=SLES12-Debuginfo-Pool for sle-12-x86_64
-baseurl=https://smt.test.lan/repo/SUSE/Products/SLE-SERVER/12/x86_64/product_debug
-autorefresh=0
-enabled=0
+[SLES12-Updates]
+name=SLES12-Updates for sle-12-x86_64
+baseurl=https://smt.test.lan/repo/SUSE/Updates/SLE-SERVER/12/x86_64/update
+autorefresh=1
+enabled=1
 
 `
 	testServiceOutput(t, "testdata/products-sle12.json", expectedOutput,
 		func(buffer *bytes.Buffer, products []Product) {
-			DumpRepositories(buffer, products[0])
+			DumpProducts(buffer, []Product{products[0]})
 		})
 }
 
-func TestServiceOutputSLE15(t *testing.T) {
-	const expectedOutput = `# generated by container-suseconnect
+func TestServiceOutputSLE12WithRepositoryPatterns(t *testing.T) {
+	t.Setenv("ENABLE_REPOSITORIES", "*-Debuginfo-*, SLES12-Pool")
+	t.Setenv("DISABLE_REPOSITORIES", "SLES12-Debuginfo-Pool,SLES12-Pool")
 
-[SLE15-Installer-Updates]
-name=SLE15-Installer-Updates for sle-15-x86_64
-baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Updates/SLE-INSTALLER/15/x86_64/update/?credentials=SMT-http_smt-ec2_susecloud_net
-autorefresh=1
-enabled=0
+	const expectedOutput = `# generated by container-suseconnect
 
-[SLE-Product-SLES15-Pool]
-name=SLE-Product-SLES15-Pool for sle-15-x86_64
-baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Products/SLE-Product-SLES/15/x86_64/product/?credentials=SMT-http_smt-ec2_susecloud_net
+[SLES12-Debuginfo-Pool]
+name=SLES12-Debuginfo-Pool for sle-12-x86_64
+baseurl=https://smt.test.lan/repo/SUSE/Products/SLE-SERVER/12/x86_64/product_debug
 autorefresh=0
-enabled=1
+enabled=0
 
-[SLE-Product-SLES15-Updates]
-name=SLE-Product-SLES15-Updates for sle-15-x86_64
-baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Updates/SLE-Product-SLES/15/x86_64/update/?credentials=SMT-http_smt-ec2_susecloud_net
+[SLES12-Debuginfo-Updates]
+name=SLES12-Debuginfo-Updates for sle-12-x86_64
+baseurl=https://smt.test.lan/repo/SUSE/Updates/SLE-SERVER/12/x86_64/update_debug
 autorefresh=1
 enabled=1
 
-[SLE-Product-SLES15-Debuginfo-Updates]
-name=SLE-Product-SLES15-Debuginfo-Updates for sle-15-x86_64
-baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Updates/SLE-Product-SLES/15/x86_64/update_debug/?credentials=SMT-http_smt-ec2_susecloud_net
-autorefresh=1
-enabled=0
-
-[SLE-Product-SLES15-Source-Pool]
-name=SLE-Product-SLES15-Source-Pool for sle-15-x86_64
-baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Products/SLE-Product-SLES/15/x86_64/product_source/?credentials=SMT-http_smt-ec2_susecloud_net
-autorefresh=0
-enabled=0
-
-[SLE-Product-SLES15-Debuginfo-Pool]
-name=SLE-Product-SLES15-Debuginfo-Pool for sle-15-x86_64
-baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Products/SLE-Product-SLES/15/x86_64/product_debug/?credentials=SMT-http_smt-ec2_susecloud_net
+[SLES12-Pool]
+name=SLES12-Pool for sle-12-x86_64
+baseurl=https://smt.test.lan/repo/SUSE/Products/SLE-SERVER/12/x86_64/product
 autorefresh=0
 enabled=0
 
-[SLE-Module-Basesystem15-Updates]
-name=SLE-Module-Basesystem15-Updates for sle-15-x86_64
-baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Updates/SLE-Module-Basesystem/15/x86_64/update/?credentials=SMT-http_smt-ec2_susecloud_net
+[SLES12-Updates]
+name=SLES12-Updates for sle-12-x86_64
+baseurl=https://smt.test.lan/repo/SUSE/Updates/SLE-SERVER/12/x86_64/update
 autorefresh=1
 enabled=1
 
-[SLE-Module-Basesystem15-Source-Pool]
-name=SLE-Module-Basesystem15-Source-Pool for sle-15-x86_64
-baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Products/SLE-Module-Basesystem/15/x86_64/product_source/?credentials=SMT-http_smt-ec2_susecloud_net
+`
+	testServiceOutput(t, "testdata/products-sle12.json", expectedOutput,
+		func(buffer *bytes.Buffer, products []Product) {
+			DumpProducts(buffer, []Product{products[0]})
+		})
+}
+
+func TestServiceListProductsWithRepositoryPatterns(t *testing.T) {
+	t.Setenv("ENABLE_REPOSITORIES", "SLES12-Debuginfo-Updates")
+
+	reader, err := os.Open("testdata/products-sle12.json")
+	if err != nil {
+		t.Fatal("Could not read JSON file...")
+	}
+	defer reader.Close()
+
+	products, err := parseProducts(reader)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	buffer := bytes.Buffer{}
+	ListProducts(&buffer, products, "none")
+
+	expected := "2. SLES12-Debuginfo-Updates: https://smt.test.lan/repo/SUSE/Updates/SLE-SERVER/12/x86_64/update_debug (enabled)"
+	if !strings.Contains(buffer.String(), expected) {
+		t.Fatalf("%v\nshould contain\n%v", buffer.String(), expected)
+	}
+}
+
+func TestServiceOutputSLE15(t *testing.T) {
+	const expectedOutput = `# generated by container-suseconnect
+
+[SLE-Module-Basesystem15-Debuginfo-Pool]
+name=SLE-Module-Basesystem15-Debuginfo-Pool for sle-15-x86_64
+baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Products/SLE-Module-Basesystem/15/x86_64/product_debug/?credentials=SMT-http_smt-ec2_susecloud_net
 autorefresh=0
 enabled=0
 
@@ -156,18 +176,24 @@ baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Products/SLE-Module-Basesystem/15
 autorefresh=0
 enabled=1
 
-[SLE-Module-Basesystem15-Debuginfo-Pool]
-name=SLE-Module-Basesystem15-Debuginfo-Pool for sle-15-x86_64
-baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Products/SLE-Module-Basesystem/15/x86_64/product_debug/?credentials=SMT-http_smt-ec2_susecloud_net
+[SLE-Module-Basesystem15-Source-Pool]
+name=SLE-Module-Basesystem15-Source-Pool for sle-15-x86_64
+baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Products/SLE-Module-Basesystem/15/x86_64/product_source/?credentials=SMT-http_smt-ec2_susecloud_net
 autorefresh=0
 enabled=0
 
-[SLE-Module-Server-Applications15-Updates]
-name=SLE-Module-Server-Applications15-Updates for sle-15-x86_64
-baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Updates/SLE-Module-Server-Applications/15/x86_64/update/?credentials=SMT-http_smt-ec2_susecloud_net
+[SLE-Module-Basesystem15-Updates]
+name=SLE-Module-Basesystem15-Updates for sle-15-x86_64
+baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Updates/SLE-Module-Basesystem/15/x86_64/update/?credentials=SMT-http_smt-ec2_susecloud_net
 autorefresh=1
 enabled=1
 
+[SLE-Module-Server-Applications15-Debuginfo-Pool]
+name=SLE-Module-Server-Applications15-Debuginfo-Pool for sle-15-x86_64
+baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Products/SLE-Module-Server-Applications/15/x86_64/product_debug/?credentials=SMT-http_smt-ec2_susecloud_net
+autorefresh=0
+enabled=0
+
 [SLE-Module-Server-Applications15-Debuginfo-Updates]
 name=SLE-Module-Server-Applications15-Debuginfo-Updates for sle-15-x86_64
 baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Updates/SLE-Module-Server-Applications/15/x86_64/update_debug/?credentials=SMT-http_smt-ec2_susecloud_net
@@ -180,36 +206,27 @@ baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Products/SLE-Module-Server-Applic
 autorefresh=0
 enabled=1
 
-[SLE-Module-Server-Applications15-Debuginfo-Pool]
-name=SLE-Module-Server-Applications15-Debuginfo-Pool for sle-15-x86_64
-baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Products/SLE-Module-Server-Applications/15/x86_64/product_debug/?credentials=SMT-http_smt-ec2_susecloud_net
-autorefresh=0
-enabled=0
-
 [SLE-Module-Server-Applications15-Source-Pool]
 name=SLE-Module-Server-Applications15-Source-Pool for sle-15-x86_64
 baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Products/SLE-Module-Server-Applications/15/x86_64/product_source/?credentials=SMT-http_smt-ec2_susecloud_net
 autorefresh=0
 enabled=0
 
-`
-
-	testServiceOutput(t, "testdata/products-sle15.json", expectedOutput,
-		func(buffer *bytes.Buffer, products []Product) {
-			DumpRepositories(buffer, products[0])
-		})
-}
+[SLE-Module-Server-Applications15-Updates]
+name=SLE-Module-Server-Applications15-Updates for sle-15-x86_64
+baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Updates/SLE-Module-Server-Applications/15/x86_64/update/?credentials=SMT-http_smt-ec2_susecloud_net
+autorefresh=1
+enabled=1
 
-func TestServiceOutputSLE15WithCustomModules(t *testing.T) {
-	os.Setenv(
-		"ADDITIONAL_MODULES",
-		"sle-module-desktop-applications,sle-module-development-tools",
-	)
-	const expectedOutput = `# generated by container-suseconnect
+[SLE-Product-SLES15-Debuginfo-Pool]
+name=SLE-Product-SLES15-Debuginfo-Pool for sle-15-x86_64
+baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Products/SLE-Product-SLES/15/x86_64/product_debug/?credentials=SMT-http_smt-ec2_susecloud_net
+autorefresh=0
+enabled=0
 
-[SLE15-Installer-Updates]
-name=SLE15-Installer-Updates for sle-15-x86_64
-baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Updates/SLE-INSTALLER/15/x86_64/update/?credentials=SMT-http_smt-ec2_susecloud_net
+[SLE-Product-SLES15-Debuginfo-Updates]
+name=SLE-Product-SLES15-Debuginfo-Updates for sle-15-x86_64
+baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Updates/SLE-Product-SLES/15/x86_64/update_debug/?credentials=SMT-http_smt-ec2_susecloud_net
 autorefresh=1
 enabled=0
 
@@ -219,39 +236,42 @@ baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Products/SLE-Product-SLES/15/x86_
 autorefresh=0
 enabled=1
 
+[SLE-Product-SLES15-Source-Pool]
+name=SLE-Product-SLES15-Source-Pool for sle-15-x86_64
+baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Products/SLE-Product-SLES/15/x86_64/product_source/?credentials=SMT-http_smt-ec2_susecloud_net
+autorefresh=0
+enabled=0
+
 [SLE-Product-SLES15-Updates]
 name=SLE-Product-SLES15-Updates for sle-15-x86_64
 baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Updates/SLE-Product-SLES/15/x86_64/update/?credentials=SMT-http_smt-ec2_susecloud_net
 autorefresh=1
 enabled=1
 
-[SLE-Product-SLES15-Debuginfo-Updates]
-name=SLE-Product-SLES15-Debuginfo-Updates for sle-15-x86_64
-baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Updates/SLE-Product-SLES/15/x86_64/update_debug/?credentials=SMT-http_smt-ec2_susecloud_net
+[SLE15-Installer-Updates]
+name=SLE15-Installer-Updates for sle-15-x86_64
+baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Updates/SLE-INSTALLER/15/x86_64/update/?credentials=SMT-http_smt-ec2_susecloud_net
 autorefresh=1
 enabled=0
 
-[SLE-Product-SLES15-Source-Pool]
-name=SLE-Product-SLES15-Source-Pool for sle-15-x86_64
-baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Products/SLE-Product-SLES/15/x86_64/product_source/?credentials=SMT-http_smt-ec2_susecloud_net
-autorefresh=0
-enabled=0
+`
 
-[SLE-Product-SLES15-Debuginfo-Pool]
-name=SLE-Product-SLES15-Debuginfo-Pool for sle-15-x86_64
-baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Products/SLE-Product-SLES/15/x86_64/product_debug/?credentials=SMT-http_smt-ec2_susecloud_net
-autorefresh=0
-enabled=0
+	testServiceOutput(t, "testdata/products-sle15.json", expectedOutput,
+		func(buffer *bytes.Buffer, products []Product) {
+			DumpProducts(buffer, []Product{products[0]})
+		})
+}
 
-[SLE-Module-Basesystem15-Updates]
-name=SLE-Module-Basesystem15-Updates for sle-15-x86_64
-baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Updates/SLE-Module-Basesystem/15/x86_64/update/?credentials=SMT-http_smt-ec2_susecloud_net
-autorefresh=1
-enabled=1
+func TestServiceOutputSLE15WithCustomModules(t *testing.T) {
+	os.Setenv(
+		"ADDITIONAL_MODULES",
+		"sle-module-desktop-applications,sle-module-development-tools",
+	)
+	const expectedOutput = `# generated by container-suseconnect
 
-[SLE-Module-Basesystem15-Source-Pool]
-name=SLE-Module-Basesystem15-Source-Pool for sle-15-x86_64
-baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Products/SLE-Module-Basesystem/15/x86_64/product_source/?credentials=SMT-http_smt-ec2_susecloud_net
+[SLE-Module-Basesystem15-Debuginfo-Pool]
+name=SLE-Module-Basesystem15-Debuginfo-Pool for sle-15-x86_64
+baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Products/SLE-Module-Basesystem/15/x86_64/product_debug/?credentials=SMT-http_smt-ec2_susecloud_net
 autorefresh=0
 enabled=0
 
@@ -267,48 +287,24 @@ baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Products/SLE-Module-Basesystem/15
 autorefresh=0
 enabled=1
 
-[SLE-Module-Basesystem15-Debuginfo-Pool]
-name=SLE-Module-Basesystem15-Debuginfo-Pool for sle-15-x86_64
-baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Products/SLE-Module-Basesystem/15/x86_64/product_debug/?credentials=SMT-http_smt-ec2_susecloud_net
+[SLE-Module-Basesystem15-Source-Pool]
+name=SLE-Module-Basesystem15-Source-Pool for sle-15-x86_64
+baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Products/SLE-Module-Basesystem/15/x86_64/product_source/?credentials=SMT-http_smt-ec2_susecloud_net
 autorefresh=0
 enabled=0
 
-[SLE-Module-Server-Applications15-Updates]
-name=SLE-Module-Server-Applications15-Updates for sle-15-x86_64
-baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Updates/SLE-Module-Server-Applications/15/x86_64/update/?credentials=SMT-http_smt-ec2_susecloud_net
-autorefresh=1
-enabled=1
-
-[SLE-Module-Server-Applications15-Debuginfo-Updates]
-name=SLE-Module-Server-Applications15-Debuginfo-Updates for sle-15-x86_64
-baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Updates/SLE-Module-Server-Applications/15/x86_64/update_debug/?credentials=SMT-http_smt-ec2_susecloud_net
+[SLE-Module-Basesystem15-Updates]
+name=SLE-Module-Basesystem15-Updates for sle-15-x86_64
+baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Updates/SLE-Module-Basesystem/15/x86_64/update/?credentials=SMT-http_smt-ec2_susecloud_net
 autorefresh=1
-enabled=0
-
-[SLE-Module-Server-Applications15-Pool]
-name=SLE-Module-Server-Applications15-Pool for sle-15-x86_64
-baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Products/SLE-Module-Server-Applications/15/x86_64/product/?credentials=SMT-http_smt-ec2_susecloud_net
-autorefresh=0
 enabled=1
 
-[SLE-Module-Server-Applications15-Debuginfo-Pool]
-name=SLE-Module-Server-Applications15-Debuginfo-Pool for sle-15-x86_64
-baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Products/SLE-Module-Server-Applications/15/x86_64/product_debug/?credentials=SMT-http_smt-ec2_susecloud_net
-autorefresh=0
-enabled=0
-
-[SLE-Module-Server-Applications15-Source-Pool]
-name=SLE-Module-Server-Applications15-Source-Pool for sle-15-x86_64
-baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Products/SLE-Module-Server-Applications/15/x86_64/product_source/?credentials=SMT-http_smt-ec2_susecloud_net
+[SLE-Module-Desktop-Applications15-Debuginfo-Pool]
+name=SLE-Module-Desktop-Applications15-Debuginfo-Pool for sle-15-x86_64
+baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Products/SLE-Module-Desktop-Applications/15/x86_64/product_debug/?credentials=SMT-http_smt-ec2_susecloud_net
 autorefresh=0
 enabled=0
 
-[SLE-Module-Desktop-Applications15-Updates]
-name=SLE-Module-Desktop-Applications15-Updates for sle-15-x86_64
-baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Updates/SLE-Module-Desktop-Applications/15/x86_64/update/?credentials=SMT-http_smt-ec2_susecloud_net
-autorefresh=1
-enabled=1
-
 [SLE-Module-Desktop-Applications15-Debuginfo-Updates]
 name=SLE-Module-Desktop-Applications15-Debuginfo-Updates for sle-15-x86_64
 baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Updates/SLE-Module-Desktop-Applications/15/x86_64/update_debug/?credentials=SMT-http_smt-ec2_susecloud_net
@@ -321,24 +317,24 @@ baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Products/SLE-Module-Desktop-Appli
 autorefresh=0
 enabled=1
 
-[SLE-Module-Desktop-Applications15-Debuginfo-Pool]
-name=SLE-Module-Desktop-Applications15-Debuginfo-Pool for sle-15-x86_64
-baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Products/SLE-Module-Desktop-Applications/15/x86_64/product_debug/?credentials=SMT-http_smt-ec2_susecloud_net
-autorefresh=0
-enabled=0
-
 [SLE-Module-Desktop-Applications15-Source-Pool]
 name=SLE-Module-Desktop-Applications15-Source-Pool for sle-15-x86_64
 baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Products/SLE-Module-Desktop-Applications/15/x86_64/product_source/?credentials=SMT-http_smt-ec2_susecloud_net
 autorefresh=0
 enabled=0
 
-[SLE-Module-DevTools15-Updates]
-name=SLE-Module-DevTools15-Updates for sle-15-x86_64
-baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Updates/SLE-Module-Development-Tools/15/x86_64/update/?credentials=SMT-http_smt-ec2_susecloud_net
+[SLE-Module-Desktop-Applications15-Updates]
+name=SLE-Module-Desktop-Applications15-Updates for sle-15-x86_64
+baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Updates/SLE-Module-Desktop-Applications/15/x86_64/update/?credentials=SMT-http_smt-ec2_susecloud_net
 autorefresh=1
 enabled=1
 
+[SLE-Module-DevTools15-Debuginfo-Pool]
+name=SLE-Module-DevTools15-Debuginfo-Pool for sle-15-x86_64
+baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Products/SLE-Module-Development-Tools/15/x86_64/product_debug/?credentials=SMT-http_smt-ec2_susecloud_net
+autorefresh=0
+enabled=0
+
 [SLE-Module-DevTools15-Debuginfo-Updates]
 name=SLE-Module-DevTools15-Debuginfo-Updates for sle-15-x86_64
 baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Updates/SLE-Module-Development-Tools/15/x86_64/update_debug/?credentials=SMT-http_smt-ec2_susecloud_net
@@ -351,23 +347,89 @@ baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Products/SLE-Module-Development-T
 autorefresh=0
 enabled=1
 
-[SLE-Module-DevTools15-Debuginfo-Pool]
-name=SLE-Module-DevTools15-Debuginfo-Pool for sle-15-x86_64
-baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Products/SLE-Module-Development-Tools/15/x86_64/product_debug/?credentials=SMT-http_smt-ec2_susecloud_net
-autorefresh=0
-enabled=0
-
 [SLE-Module-DevTools15-Source-Pool]
 name=SLE-Module-DevTools15-Source-Pool for sle-15-x86_64
 baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Products/SLE-Module-Development-Tools/15/x86_64/product_source/?credentials=SMT-http_smt-ec2_susecloud_net
 autorefresh=0
 enabled=0
 
+[SLE-Module-DevTools15-Updates]
+name=SLE-Module-DevTools15-Updates for sle-15-x86_64
+baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Updates/SLE-Module-Development-Tools/15/x86_64/update/?credentials=SMT-http_smt-ec2_susecloud_net
+autorefresh=1
+enabled=1
+
+[SLE-Module-Server-Applications15-Debuginfo-Pool]
+name=SLE-Module-Server-Applications15-Debuginfo-Pool for sle-15-x86_64
+baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Products/SLE-Module-Server-Applications/15/x86_64/product_debug/?credentials=SMT-http_smt-ec2_susecloud_net
+autorefresh=0
+enabled=0
+
+[SLE-Module-Server-Applications15-Debuginfo-Updates]
+name=SLE-Module-Server-Applications15-Debuginfo-Updates for sle-15-x86_64
+baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Updates/SLE-Module-Server-Applications/15/x86_64/update_debug/?credentials=SMT-http_smt-ec2_susecloud_net
+autorefresh=1
+enabled=0
+
+[SLE-Module-Server-Applications15-Pool]
+name=SLE-Module-Server-Applications15-Pool for sle-15-x86_64
+baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Products/SLE-Module-Server-Applications/15/x86_64/product/?credentials=SMT-http_smt-ec2_susecloud_net
+autorefresh=0
+enabled=1
+
+[SLE-Module-Server-Applications15-Source-Pool]
+name=SLE-Module-Server-Applications15-Source-Pool for sle-15-x86_64
+baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Products/SLE-Module-Server-Applications/15/x86_64/product_source/?credentials=SMT-http_smt-ec2_susecloud_net
+autorefresh=0
+enabled=0
+
+[SLE-Module-Server-Applications15-Updates]
+name=SLE-Module-Server-Applications15-Updates for sle-15-x86_64
+baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Updates/SLE-Module-Server-Applications/15/x86_64/update/?credentials=SMT-http_smt-ec2_susecloud_net
+autorefresh=1
+enabled=1
+
+[SLE-Product-SLES15-Debuginfo-Pool]
+name=SLE-Product-SLES15-Debuginfo-Pool for sle-15-x86_64
+baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Products/SLE-Product-SLES/15/x86_64/product_debug/?credentials=SMT-http_smt-ec2_susecloud_net
+autorefresh=0
+enabled=0
+
+[SLE-Product-SLES15-Debuginfo-Updates]
+name=SLE-Product-SLES15-Debuginfo-Updates for sle-15-x86_64
+baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Updates/SLE-Product-SLES/15/x86_64/update_debug/?credentials=SMT-http_smt-ec2_susecloud_net
+autorefresh=1
+enabled=0
+
+[SLE-Product-SLES15-Pool]
+name=SLE-Product-SLES15-Pool for sle-15-x86_64
+baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Products/SLE-Product-SLES/15/x86_64/product/?credentials=SMT-http_smt-ec2_susecloud_net
+autorefresh=0
+enabled=1
+
+[SLE-Product-SLES15-Source-Pool]
+name=SLE-Product-SLES15-Source-Pool for sle-15-x86_64
+baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Products/SLE-Product-SLES/15/x86_64/product_source/?credentials=SMT-http_smt-ec2_susecloud_net
+autorefresh=0
+enabled=0
+
+[SLE-Product-SLES15-Updates]
+name=SLE-Product-SLES15-Updates for sle-15-x86_64
+baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Updates/SLE-Product-SLES/15/x86_64/update/?credentials=SMT-http_smt-ec2_susecloud_net
+autorefresh=1
+enabled=1
+
+[SLE15-Installer-Updates]
+name=SLE15-Installer-Updates for sle-15-x86_64
+baseurl=http://smt-ec2.susecloud.net/repo/SUSE/Updates/SLE-INSTALLER/15/x86_64/update/?credentials=SMT-http_smt-ec2_susecloud_net
+autorefresh=1
+enabled=0
+
 `
 
 	testServiceOutput(t, "testdata/products-sle15.json", expectedOutput,
 		func(buffer *bytes.Buffer, products []Product) {
-			DumpRepositories(buffer, products[0])
+			DumpProducts(buffer, []Product{products[0]})
 		})
 }
 
@@ -615,3 +677,82 @@ Repositories:
 			ListProducts(buffer, products, "none")
 		})
 }
+
+func TestModuleEnabledInProductFiles(t *testing.T) {
+	old := productsDir
+	defer func() { productsDir = old }()
+
+	prepareLogger()
+
+	productsDir = t.TempDir()
+	prod := func(version, arch string) string {
+		return "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<product schemeversion=\"0\">\n" +
+			"  <name>sle-module</name>\n  <version>" + version + "</version>\n  <arch>" + arch + "</arch>\n</product>\n"
+	}
+	writeScrubFiles(t, productsDir, map[string]string{
+		"sle-module-containers.prod":    prod("15", "x86_64"),
+		"sle-module-legacy.prod":        prod("12", "x86_64"),
+		"sle-module-web-scripting.prod": prod("15", "aarch64"),
+		"sle-module-broken.prod":        "<product",
+	})
+	if err := os.Symlink("sle-module-containers.prod", filepath.Join(productsDir, "sle-module-public-cloud.prod")); err != nil {
+		t.Fatalf("Could not create symlink: %v", err)
+	}
+
+	cases := map[string]bool{
+		"sle-module-containers":    true,
+		"sle-module-legacy":        false,
+		"sle-module-web-scripting": false,
+		"sle-module-broken":        false,
+		"sle-module-public-cloud":  false,
+		"sle-module-missing":       false,
+	}
+	for identifier, expected := range cases {
+		product := Product{Identifier: identifier, Version: "15", Arch: "x86_64"}
+		if moduleEnabledInProductFiles(product) != expected {
+			t.Fatalf("Expected %v for %v", expected, identifier)
+		}
+	}
+
+	if !strings.Contains(logged.String(), "sle-module-legacy.prod, it is for 12-x86_64 but the registration server provides 15-x86_64") {
+		t.Fatalf("Expected a warning about the stale file, got: %v", logged.String())
+	}
+}
+
+func TestServiceOutputSLE15Minimal(t *testing.T) {
+	withDropIns(t, nil)
+	t.Setenv(MinimalEnv, "true")
+	t.Setenv("ADDITIONAL_MODULES", "sle-module-containers")
+
+	products := readProducts(t, "testdata/products-sle15.json")
+
+	buffer := bytes.Buffer{}
+	DumpProducts(&buffer, []Product{products[0]})
+
+	if !strings.Contains(buffer.String(), "[SLE-Product-SLES15-Pool]") {
+		t.Fatalf("The base product should always be dumped:\n%v", buffer.String())
+	}
+	if !strings.Contains(buffer.String(), "[SLE-Module-Containers15-Pool]") {
+		t.Fatalf("Requested modules should be dumped:\n%v", buffer.String())
+	}
+	if strings.Contains(buffer.String(), "[SLE-Module-Basesystem15-Pool]") {
+		t.Fatalf("Recommended modules should be skipped:\n%v", buffer.String())
+	}
+}
+
+func TestServiceOutputWithSpacedModules(t *testing.T) {
+	withDropIns(t, nil)
+	t.Setenv(MinimalEnv, "true")
+	t.Setenv("ADDITIONAL_MODULES", "sle-module-basesystem, sle-module-containers")
+
+	products := readProducts(t, "testdata/products-sle15.json")
+
+	buffer := bytes.Buffer{}
+	DumpProducts(&buffer, []Product{products[0]})
+
+	for _, section := range []string{"[SLE-Module-Basesystem15-Pool]", "[SLE-Module-Containers15-Pool]"} {
+		if !strings.Contains(buffer.String(), section) {
+			t.Fatalf("Requested modules should be dumped regardless of the spaces, %v is missing:\n%v", section, buffer.String())
+		}
+	}
+}
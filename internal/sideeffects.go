@@ -0,0 +1,31 @@
+// Copyright (c) 2026 SUSE LLC. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containersuseconnect
+
+import (
+	"os"
+	"strings"
+)
+
+// WritableDirEnv is the environment variable used to specify a directory into
+// which every file written by this application is redirected. This is needed
+// on read-only root filesystems and when running as a non-root user.
+const WritableDirEnv = "CONTAINER_SUSECONNECT_WRITABLE_DIR"
+
+// WritableDir returns the directory given through WritableDirEnv, or an empty
+// string if none was given.
+func WritableDir() string {
+	return strings.TrimSpace(os.Getenv(WritableDirEnv))
+}
@@ -15,7 +15,6 @@
 package containersuseconnect
 
 import (
-	"crypto/tls"
 	"encoding/json"
 	"io"
 	"log"
@@ -50,14 +49,7 @@ func requestRegcodes(data SUSEConnectData, credentials Credentials) ([]string, e
 		req.Header.Add("System-Token", credentials.SystemToken)
 	}
 
-	client := &http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: data.Insecure,
-			},
-			Proxy: http.ProxyFromEnvironment,
-		},
-	}
+	client := newHTTPClient(data)
 
 	resp, err := client.Do(req)
 	if err != nil {
@@ -26,6 +26,22 @@ const (
 type SUSEConnectData struct {
 	SccURL   string
 	Insecure bool
+
+	// ServerType is the type of the registration server, if given by the
+	// `server_type` key. Otherwise it is detected by DetectServerType.
+	ServerType ServerType
+
+	// serverTypeName is the raw value of the `server_type` key.
+	serverTypeName string
+
+	// PinnedHosts maps hostnames to the IP addresses that should be used
+	// when connecting to them, bypassing the name resolution of the system.
+	PinnedHosts map[string]string
+
+	// CA is a PEM-encoded certificate which is trusted on top of the system
+	// ones, like the CA of the update servers given by containerbuild-regionsrv
+	// which might not be in the system trust store.
+	CA string
 }
 
 func (data *SUSEConnectData) separator() byte {
@@ -47,6 +63,8 @@ func (data *SUSEConnectData) setValues(key, value string) {
 		data.SccURL = value
 	case "insecure":
 		data.Insecure = value == "true"
+	case "server_type":
+		data.serverTypeName = value
 	default:
 		log.Printf("Warning: Unknown key '%v'", key)
 	}
@@ -57,5 +75,13 @@ func (data *SUSEConnectData) afterParseCheck() error {
 		data.SccURL = sccURLStr
 	}
 
+	if data.serverTypeName != "" {
+		serverType, ok := parseServerType(data.serverTypeName)
+		if !ok {
+			return loggedError(ConfigurationError, "Unknown server type '%v', expected one of scc, rmt, smt or suse-manager", data.serverTypeName)
+		}
+		data.ServerType = serverType
+	}
+
 	return nil
 }
@@ -0,0 +1,161 @@
+// Copyright (c) 2026 SUSE LLC. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containersuseconnect
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/SUSE/container-suseconnect/internal/regionsrv"
+)
+
+// CredentialsFileEnv is the environment variable used to specify a custom
+// path for the zypp credentials file generated for the repositories.
+const CredentialsFileEnv = "CONTAINER_SUSECONNECT_CREDENTIALS_FILE"
+
+// credentialsFileName is the name of the generated zypp credentials file in
+// the runtime directory, which is used unless CredentialsFileEnv is set.
+const credentialsFileName = "credentials"
+
+// credentialsFilePath returns the path of the generated zypp credentials
+// file.
+func credentialsFilePath() (string, error) {
+	if path := strings.TrimSpace(os.Getenv(CredentialsFileEnv)); path != "" {
+		return path, nil
+	}
+
+	return regionsrv.RuntimeFilePath(credentialsFileName)
+}
+
+// credentialsName returns the name under which the zypp credentials file at
+// the given path is referenced by the repositories. zypp looks up this name in
+// its credentials directory, so files elsewhere are referenced relative to it.
+// This way nothing has to be written into that directory, which would end up
+// in the layers of the image.
+func credentialsName(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Rel(credentialsDir, path)
+}
+
+// WriteCredentialsFile writes the given credentials into a zypp credentials
+// file, so zypper authenticates with the credentials actually used to talk to
+// the registration server, regardless of whether they came from a file, the
+// environment or the containerbuild-regionsrv service. The file is written
+// into the runtime directory (or the path given by CredentialsFileEnv), so it
+// does not end up in the layers of the image. It returns the name under which
+// the repositories have to reference it.
+func WriteCredentialsFile(credentials Credentials) (string, error) {
+	path, err := credentialsFilePath()
+	if err != nil {
+		return "", err
+	}
+
+	name, err := credentialsName(path)
+	if err != nil {
+		return "", err
+	}
+
+	data := fmt.Sprintf("username=%s\npassword=%s\n", credentials.Username, credentials.Password)
+	if err := regionsrv.WritePrivateFile(path, []byte(data)); err != nil {
+		return "", err
+	}
+
+	return name, nil
+}
+
+// RemoveCredentialsFile removes the generated zypp credentials file, if it
+// exists.
+func RemoveCredentialsFile() error {
+	path, err := credentialsFilePath()
+	if err != nil {
+		return nil
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// repositoryCredentials returns the parsed URL of the given repository if it
+// authenticates against the registration server: either with a zypp
+// credentials file (as given by RMT, SMT and SUSE Manager servers), or with a
+// token in its query (as given by SCC). Repositories without any query, like
+// the ones rewritten to a mirror without their authentication, are public.
+func repositoryCredentials(repo Repository) (*url.URL, bool) {
+	repoURL, err := url.Parse(repo.URL)
+	if err != nil || repoURL.RawQuery == "" || (repoURL.Scheme != "http" && repoURL.Scheme != "https") {
+		return nil, false
+	}
+
+	return repoURL, true
+}
+
+// UsesCredentialsFile returns true if any repository of the given products
+// authenticates against the registration server.
+func UsesCredentialsFile(products []Product) bool {
+	for _, product := range products {
+		for _, repo := range product.Repositories {
+			if _, ok := repositoryCredentials(repo); ok {
+				return true
+			}
+		}
+
+		if UsesCredentialsFile(product.Extensions) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// UseCredentialsFile makes the repositories of the given products which
+// authenticate against the registration server reference the zypp credentials
+// file with the given name, so all of them use the same credentials. This
+// replaces the references to `SCCcredentials` added when the products are
+// parsed (see fixRepoUrlsForRMT) and to the credentials files created by
+// SUSEConnect on the host (e.g. `SMT-http_smt_example_com`). The tokens of the
+// repositories from SCC are kept as they are, since they are checked verbatim
+// by the server, and zypp drops the `credentials` parameter from the requests.
+func UseCredentialsFile(products []Product, name string) {
+	for i := range products {
+		for j := range products[i].Repositories {
+			repo := &products[i].Repositories[j]
+
+			repoURL, ok := repositoryCredentials(*repo)
+			if !ok {
+				continue
+			}
+
+			if params := repoURL.Query(); params.Has("credentials") {
+				params.Set("credentials", name)
+				repoURL.RawQuery = params.Encode()
+			} else {
+				repoURL.RawQuery += "&credentials=" + url.QueryEscape(name)
+			}
+			repo.URL = repoURL.String()
+		}
+
+		UseCredentialsFile(products[i].Extensions, name)
+	}
+}
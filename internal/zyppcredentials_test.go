@@ -0,0 +1,137 @@
+// Copyright (c) 2026 SUSE LLC. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containersuseconnect
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SUSE/container-suseconnect/internal/regionsrv"
+)
+
+// withCredentialsDir redirects the credentials directory of zypp for the rest
+// of the test.
+func withCredentialsDir(t *testing.T) string {
+	old := credentialsDir
+	t.Cleanup(func() { credentialsDir = old })
+
+	credentialsDir = t.TempDir()
+	return credentialsDir
+}
+
+func TestWriteCredentialsFile(t *testing.T) {
+	runtime := t.TempDir()
+	t.Setenv(regionsrv.RuntimeDirEnv, runtime)
+	t.Setenv(CredentialsFileEnv, "")
+	dir := withCredentialsDir(t)
+
+	name, err := WriteCredentialsFile(Credentials{Username: "user", Password: "pass", SystemToken: "token"})
+	if err != nil {
+		t.Fatalf("Could not write the credentials file: %v", err)
+	}
+
+	// zypp looks up the name in its credentials directory.
+	path := filepath.Join(runtime, credentialsFileName)
+	if resolved := filepath.Join(dir, name); resolved != path {
+		t.Fatalf("The name '%v' of the credentials file resolves to %v instead of %v", name, resolved, path)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("Nothing should be written into the credentials directory, got: %v", entries)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Could not stat the credentials file: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Fatalf("The credentials file should only be readable by its owner, got %v", info.Mode().Perm())
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Could not open the credentials file: %v", err)
+	}
+	defer file.Close()
+
+	credentials := Credentials{}
+	if err := parse(&credentials, file); err != nil {
+		t.Fatalf("Could not parse the credentials file: %v", err)
+	}
+	if credentials.Username != "user" || credentials.Password != "pass" {
+		t.Fatalf("Unexpected credentials: %+v", credentials)
+	}
+
+	if err := RemoveCredentialsFile(); err != nil {
+		t.Fatalf("Could not remove the credentials file: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatal("The credentials file should have been removed")
+	}
+}
+
+func TestWriteCredentialsFileCustomPath(t *testing.T) {
+	dir := withCredentialsDir(t)
+
+	// Files in the credentials directory are referenced by their name.
+	custom := filepath.Join(dir, "custom")
+	t.Setenv(CredentialsFileEnv, custom)
+
+	name, err := WriteCredentialsFile(Credentials{Username: "user", Password: "pass"})
+	if err != nil || name != "custom" {
+		t.Fatalf("Expected the credentials file to be named 'custom', got '%v': %v", name, err)
+	}
+
+	if err := RemoveCredentialsFile(); err != nil {
+		t.Fatalf("Could not remove the credentials file: %v", err)
+	}
+	if _, err := os.Stat(custom); !os.IsNotExist(err) {
+		t.Fatal("The credentials file should have been removed")
+	}
+}
+
+func TestUseCredentialsFile(t *testing.T) {
+	products := readProducts(t, "testdata/products-sle15.json")
+	products[0].Repositories[1].URL = "https://updates.suse.com/SUSE/Products/SLE-Product-SLES/15/x86_64/product?abc_def"
+	products[0].Repositories[2].URL = "https://mirror.example.com/SUSE/Products/SLE-Product-SLES/15/x86_64/product_debug/"
+
+	if !UsesCredentialsFile(products) {
+		t.Fatal("The repositories of the SMT server should use a credentials file")
+	}
+
+	UseCredentialsFile(products, "../../run/credentials")
+
+	expected := "http://smt-ec2.susecloud.net/repo/SUSE/Updates/SLE-Module-Basesystem/15/x86_64/update/" +
+		"?credentials=..%2F..%2Frun%2Fcredentials"
+	if url := products[0].Extensions[0].Repositories[0].URL; url != expected {
+		t.Fatalf("Expected '%v', got '%v'", expected, url)
+	}
+
+	// The token of SCC is kept verbatim.
+	expected = "https://updates.suse.com/SUSE/Products/SLE-Product-SLES/15/x86_64/product?abc_def&credentials=..%2F..%2Frun%2Fcredentials"
+	if url := products[0].Repositories[1].URL; url != expected {
+		t.Fatalf("Expected '%v', got '%v'", expected, url)
+	}
+
+	expected = "https://mirror.example.com/SUSE/Products/SLE-Product-SLES/15/x86_64/product_debug/"
+	if url := products[0].Repositories[2].URL; url != expected {
+		t.Fatalf("Repositories without authentication should be left untouched, got '%v'", url)
+	}
+
+	public := []Product{{Repositories: []Repository{{URL: expected}}}}
+	if UsesCredentialsFile(public) {
+		t.Fatal("Repositories without authentication should not use a credentials file")
+	}
+}